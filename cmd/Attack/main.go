@@ -0,0 +1,187 @@
+package main
+
+import (
+	"ConjunctiveSSE/pkg/Database"
+	"ConjunctiveSSE/pkg/HDXT"
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/attack"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"ConjunctiveSSE/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Config 定义一个类型
+type Config struct {
+	Db             string    `json:"db"`
+	Queries        int       `json:"queries"`
+	KnownFractions []float64 `json:"known_fractions"`
+	Seed           int64     `json:"seed"`
+	Runs           []Run     `json:"runs"`
+}
+
+// Run 一组被攻击的方案和填充参数，HDXT 使用 Sparse 模式，padding 只对 ODXT 有效
+type Run struct {
+	Scheme  string  `json:"scheme"`
+	Padding string  `json:"padding"`
+	PadStep int     `json:"pad_step"`
+	Epsilon float64 `json:"epsilon"`
+	Delta   float64 `json:"delta"`
+}
+
+func main() {
+	var config Config
+	// 读取配置文件
+	file, err := os.Open("./cmd/Attack/config.json")
+	if err != nil {
+		fmt.Println("Error opening config file:", err)
+		return
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(&config)
+	if err != nil {
+		fmt.Println("Error decoding config file:", err)
+		return
+	}
+
+	fmt.Println("*********************************************")
+	fmt.Println("Attack_on: ", config.Db, "queries:", config.Queries, "known_fractions:", config.KnownFractions)
+
+	// 读取明文数据集
+	plaintextDB, err := Database.MongoDBSetup(config.Db)
+	if err != nil {
+		fmt.Println("MongoDBSetup error:", err)
+		return
+	}
+	dataset, err := scheme.LoadDataset(plaintextDB)
+	plaintextDB.Client().Disconnect(context.Background())
+	if err != nil {
+		fmt.Println("LoadDataset error:", err)
+		return
+	}
+	keywords, _ := dataset.Inverted()
+	queries := attack.SampleQueries(keywords, config.Queries, rand.New(rand.NewSource(config.Seed)))
+	fmt.Println("documents:", len(dataset), "keywords:", len(keywords), "queries:", len(queries))
+
+	for _, run := range config.Runs {
+		if err := TestAttack(config, run, dataset, queries); err != nil {
+			fmt.Println("TestAttack error:", err)
+		}
+	}
+}
+
+// TestAttack 在内存中建立方案并执行 queries，记录泄露后对每个已知文档比例运行计数攻击和体积匹配攻击，
+// 恢复率写入 result/Attack/<Scheme>/ 下的 CSV 文件
+func TestAttack(cfg Config, run Run, dataset scheme.Dataset, queries []string) error {
+	var (
+		s     scheme.Scheme
+		model attack.VolumeModel = attack.Exact
+		pad                      = run.Padding
+	)
+	switch run.Scheme {
+	case "ODXT":
+		odxt, err := scheme.NewODXT(ODXT.NewMemoryStore())
+		if err != nil {
+			return err
+		}
+		switch run.Padding {
+		case "pow2":
+			odxt.Padding = ODXT.PadPowerOfTwo
+		case "step":
+			odxt.Padding, odxt.PadStep = ODXT.PadStep, run.PadStep
+		case "laplace":
+			odxt.Padding, odxt.Epsilon, odxt.Delta = ODXT.PadLaplace, run.Epsilon, run.Delta
+		}
+		pad = odxt.Padding.String()
+		s, model = odxt, odxt.VolumeRange
+	case "HDXT":
+		hdxt, err := scheme.NewHDXT(HDXT.Sparse, run.PadStep)
+		if err != nil {
+			return err
+		}
+		pad = "auhme_step" + strconv.Itoa(run.PadStep)
+		s = hdxt
+	default:
+		return fmt.Errorf("unknown scheme %q", run.Scheme)
+	}
+	fmt.Println("*********************************************")
+	fmt.Println("Scheme:", s.Name(), "padding:", pad)
+
+	t1 := time.Now()
+	if err := s.Setup(dataset); err != nil {
+		return err
+	}
+	fmt.Println("Setup time:", time.Since(t1))
+
+	// 只记录查询阶段的泄露
+	saveTime := time.Now().Format("2006-01-02_15-04-05")
+	leakagePath := filepath.Join("result", "Leakage", s.Name(), fmt.Sprintf("%s_%s_%s.jsonl", cfg.Db, pad, saveTime))
+	recorder, err := leakage.NewRecorder(leakagePath)
+	if err != nil {
+		return err
+	}
+	switch s := s.(type) {
+	case *scheme.ODXTScheme:
+		s.Recorder = recorder
+	case *scheme.HDXTScheme:
+		s.Recorder = recorder
+	}
+	handles, err := attack.NewHandles()
+	if err != nil {
+		return err
+	}
+	err = attack.Simulate(s, recorder, handles, queries)
+	if cerr := recorder.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	events, err := leakage.ReadEvents(leakagePath)
+	if err != nil {
+		return err
+	}
+	obs := attack.Observe(events)
+	if len(obs) != len(queries) {
+		return fmt.Errorf("got %d observations for %d queries", len(obs), len(queries))
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	resultData := make([][]string, 0, 2*len(cfg.KnownFractions))
+	for _, fraction := range cfg.KnownFractions {
+		if fraction <= 0 || fraction > 1 {
+			return fmt.Errorf("known fraction %v out of (0, 1]", fraction)
+		}
+		known := attack.Known(dataset, fraction, rng)
+		attacks := []struct {
+			name    string
+			guesses map[string]string
+		}{
+			{"count", attack.CountAttack(obs, known)},
+			{"volume", attack.VolumeAttack(obs, known, model)},
+		}
+		for _, a := range attacks {
+			recovered := attack.Recovered(obs, queries, a.guesses)
+			rate := float64(recovered) / float64(len(obs))
+			fmt.Printf("known %.2f %s attack: %d/%d recovered (%.2f%%)\n", fraction, a.name, recovered, len(obs), 100*rate)
+			resultData = append(resultData, []string{
+				pad, strconv.FormatFloat(fraction, 'f', -1, 64), a.name,
+				strconv.Itoa(len(obs)), strconv.Itoa(recovered), strconv.FormatFloat(rate, 'f', 4, 64),
+			})
+		}
+	}
+
+	resultPath := filepath.Join("result", "Attack", s.Name(), fmt.Sprintf("%s_%s_%s.csv", cfg.Db, pad, saveTime))
+	resultHeader := []string{"padding", "knownFraction", "attack", "queries", "recovered", "recoveryRate"}
+	return utils.WriteResultToCSV(resultPath, resultHeader, resultData)
+}
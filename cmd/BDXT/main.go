@@ -0,0 +1,71 @@
+package main
+
+import (
+	"ConjunctiveSSE/pkg/BDXT"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config 定义一个类型
+type Config struct {
+	Db    string `json:"db"`
+	Phase string `json:"phase"`
+	Group string `json:"group"`
+}
+
+func main() {
+	var config Config
+	// 读取配置文件
+	file, err := os.Open("./cmd/BDXT/config.json")
+	if err != nil {
+		fmt.Println("Error opening config file:", err)
+		return
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(&config)
+	if err != nil {
+		fmt.Println("Error decoding config file:", err)
+		return
+	}
+
+	// 使用配置文件中的参数
+	fmt.Println("*********************************************")
+	fmt.Println("Test_on: ", config.Db)
+	fmt.Println("Start test_group:", config.Group, "phase:", config.Phase)
+	fmt.Println("Start initial db...")
+
+	// Run tests
+	err = TestBDXT(config)
+	if err != nil {
+		fmt.Println("TestBDXT error:", err)
+	}
+}
+
+// TestBDXT 客户端状态不持久化，phase 中的 c 和 s 需在同一次运行中执行
+func TestBDXT(cfg Config) error {
+	var bdxt BDXT.BDXT
+	err := bdxt.DBSetup(cfg.Db, false)
+	if err != nil {
+		fmt.Println("DBSetup error", err)
+		return err
+	}
+	if strings.Contains(cfg.Phase, "c") {
+		t1 := time.Now()
+		bdxt.CiphertextGenPhase(cfg.Db)
+		t2 := time.Since(t1)
+		fmt.Println("CiphertextGenPhase time:", t2)
+	}
+	if strings.Contains(cfg.Phase, "s") {
+		t1 := time.Now()
+		bdxt.SearchPhase(cfg.Db, cfg.Group)
+		t2 := time.Since(t1)
+		fmt.Println("SearchPhase time:", t2)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"ConjunctiveSSE/pkg/Database"
+	"ConjunctiveSSE/pkg/HDXT"
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/attack"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"ConjunctiveSSE/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Config 定义一个类型
+type Config struct {
+	Db            string   `json:"db"`
+	PastQueries   int      `json:"past_queries"`
+	FutureQueries int      `json:"future_queries"`
+	Seed          int64    `json:"seed"`
+	Schemes       []string `json:"schemes"`
+}
+
+func main() {
+	var config Config
+	// 读取配置文件
+	file, err := os.Open("./cmd/Injection/config.json")
+	if err != nil {
+		fmt.Println("Error opening config file:", err)
+		return
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	err = decoder.Decode(&config)
+	if err != nil {
+		fmt.Println("Error decoding config file:", err)
+		return
+	}
+
+	fmt.Println("*********************************************")
+	fmt.Println("Injection_on: ", config.Db, "past:", config.PastQueries, "future:", config.FutureQueries)
+
+	// 读取明文数据集
+	plaintextDB, err := Database.MongoDBSetup(config.Db)
+	if err != nil {
+		fmt.Println("MongoDBSetup error:", err)
+		return
+	}
+	dataset, err := scheme.LoadDataset(plaintextDB)
+	plaintextDB.Client().Disconnect(context.Background())
+	if err != nil {
+		fmt.Println("LoadDataset error:", err)
+		return
+	}
+	keywords, _ := dataset.Inverted()
+	queries := attack.SampleQueries(keywords, config.PastQueries+config.FutureQueries, rand.New(rand.NewSource(config.Seed)))
+	past := queries[:min(config.PastQueries, len(queries))]
+	inj, err := attack.NewInjection(keywords, past, queries[len(past):])
+	if err != nil {
+		fmt.Println("NewInjection error:", err)
+		return
+	}
+	files, entries := inj.Files()
+	fmt.Println("documents:", len(dataset), "keywords:", len(keywords), "injected files:", files, "injected entries:", entries)
+
+	saveTime := time.Now().Format("2006-01-02_15-04-05")
+	resultData := make([][]string, 0, len(config.Schemes))
+	for _, name := range config.Schemes {
+		past, future, err := TestInjection(config.Db, name, saveTime, dataset, inj)
+		if err != nil {
+			fmt.Println("TestInjection error:", err)
+			continue
+		}
+		resultData = append(resultData, []string{
+			name, strconv.Itoa(files), strconv.Itoa(entries),
+			strconv.Itoa(len(inj.Past)), strconv.Itoa(past), strconv.Itoa(len(inj.Future)), strconv.Itoa(future),
+		})
+	}
+
+	resultPath := filepath.Join("result", "Attack", "Injection", fmt.Sprintf("%s_%s.csv", config.Db, saveTime))
+	resultHeader := []string{"scheme", "injectedFiles", "injectedEntries", "pastQueries", "pastRecovered", "futureQueries", "futureRecovered"}
+	if err := utils.WriteResultToCSV(resultPath, resultHeader, resultData); err != nil {
+		fmt.Println("WriteResultToCSV error:", err)
+	}
+}
+
+// TestInjection 在内存中建立方案后执行文件注入实验，泄露写入 result/Leakage/<Scheme>/，
+// 返回注入前后的查询中被恢复的数量
+func TestInjection(db, name, saveTime string, dataset scheme.Dataset, inj *attack.Injection) (int, int, error) {
+	store := &ODXT.RecordingStore{Store: ODXT.NewMemoryStore(), Scheme: name}
+	var (
+		s           scheme.Scheme
+		setRecorder func(r *leakage.Recorder)
+	)
+	switch name {
+	case "ODXT", "ODXTNonFP":
+		odxt, err := scheme.NewODXT(store)
+		if err != nil {
+			return 0, 0, err
+		}
+		s = odxt
+		if name == "ODXTNonFP" {
+			s = &scheme.ODXTNonFPScheme{ODXTScheme: odxt}
+		}
+		setRecorder = func(r *leakage.Recorder) { store.Recorder, odxt.Recorder = r, r }
+	case "HDXT":
+		hdxt, err := scheme.NewHDXT(HDXT.Sparse, 0)
+		if err != nil {
+			return 0, 0, err
+		}
+		s = hdxt
+		setRecorder = func(r *leakage.Recorder) { hdxt.Recorder = r }
+	default:
+		return 0, 0, fmt.Errorf("unknown scheme %q", name)
+	}
+	fmt.Println("*********************************************")
+	fmt.Println("Scheme:", s.Name())
+
+	t1 := time.Now()
+	if err := s.Setup(dataset); err != nil {
+		return 0, 0, err
+	}
+	fmt.Println("Setup time:", time.Since(t1))
+
+	// 只记录建立索引之后的泄露
+	leakagePath := filepath.Join("result", "Leakage", s.Name(), fmt.Sprintf("%s_injection_%s.jsonl", db, saveTime))
+	recorder, err := leakage.NewRecorder(leakagePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	setRecorder(recorder)
+	err = inj.Run(s, recorder)
+	if cerr := recorder.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	events, err := leakage.ReadEvents(leakagePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	past, future, err := inj.Recover(events)
+	if err != nil {
+		return 0, 0, err
+	}
+	fmt.Printf("past queries recovered: %d/%d, future queries recovered: %d/%d\n", past, len(inj.Past), future, len(inj.Future))
+	return past, future, nil
+}
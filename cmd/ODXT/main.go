@@ -2,22 +2,41 @@ package main
 
 import (
 	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/oram"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
 // Config 定义一个类型
 type Config struct {
-	Db               string `json:"db"`
-	Phase            string `json:"phase"`
-	Group            string `json:"group"`
-	DelRate          int    `json:"del_rate"`
-	DBSetupFromFiles bool   `json:"db_setup_from_files"`
-	XSetPath         string `json:"xset_path"`
-	UpdateCntPath    string `json:"update_cnt_path"`
+	Db               string  `json:"db"`
+	Phase            string  `json:"phase"`
+	Group            string  `json:"group"`
+	DelRate          int     `json:"del_rate"`
+	DBSetupFromFiles bool    `json:"db_setup_from_files"`
+	XSetPath         string  `json:"xset_path"`
+	UpdateCntPath    string  `json:"update_cnt_path"`
+	HXT              bool    `json:"hxt"`
+	OutsourceCounter bool    `json:"outsource_counter"`
+	ORAMCapacity     int     `json:"oram_capacity"`
+	Padding          string  `json:"padding"`
+	PadStep          int     `json:"pad_step"`
+	MaxConjuncts     int     `json:"max_conjuncts"`
+	Epsilon          float64 `json:"epsilon"`
+	Delta            float64 `json:"delta"`
+	EpochSize        int     `json:"epoch_size"`
+	EpochInterval    string  `json:"epoch_interval"`
+	Leakage          bool    `json:"leakage"`
+	Verify           bool    `json:"verify"`
+	ValueEncoding    string  `json:"value_encoding"`
 }
 
 func main() {
@@ -51,6 +70,24 @@ func main() {
 }
 
 func TestODXT(cfg Config) error {
+	if cfg.OutsourceCounter && cfg.ORAMCapacity > 0 {
+		return errors.New("outsource_counter and oram_capacity cannot both be set")
+	}
+	if cfg.ORAMCapacity > 0 && !(strings.Contains(cfg.Phase, "c") && strings.Contains(cfg.Phase, "s")) {
+		// ORAM 的密钥、根指针和 stash 只保存在内存中，计数无法留到下一次运行
+		return errors.New(`oram_capacity requires phase "cs" in a single run`)
+	}
+	switch cfg.Padding {
+	case "", "pow2", "step", "laplace":
+	default:
+		return fmt.Errorf(`unknown padding %q, want "", "pow2", "step" or "laplace"`, cfg.Padding)
+	}
+	switch cfg.ValueEncoding {
+	case "", "xor", "aead":
+	default:
+		return fmt.Errorf(`unknown value_encoding %q, want "xor" or "aead"`, cfg.ValueEncoding)
+	}
+
 	var odxt ODXT.ODXT
 	if cfg.DBSetupFromFiles {
 		err := odxt.DBSetupFromFiles(cfg.Db, cfg.XSetPath, cfg.UpdateCntPath)
@@ -65,6 +102,116 @@ func TestODXT(cfg Config) error {
 			return err
 		}
 	}
+	if cfg.OutsourceCounter {
+		// 计数以加密形式保存在服务器端的 <db>_counter 表中
+		tableName := cfg.Db + "_counter"
+		if err := ODXT.CreateCounterTable(odxt.MySQLDB, tableName); err != nil {
+			fmt.Println("CreateCounterTable error", err)
+			return err
+		}
+		odxt.Counters = &ODXT.MySQLCounterStore{DB: odxt.MySQLDB, TableName: tableName}
+	}
+	if cfg.ORAMCapacity > 0 {
+		// 计数保存在 <db>_oram 表中的 Path ORAM 不经意映射里，容量需不少于关键字数。
+		// 每次运行都会重新初始化该表，计数只在本次运行内有效
+		bucketStore, err := oram.NewMySQLBucketStore(odxt.MySQLDB, cfg.Db+"_oram")
+		if err != nil {
+			fmt.Println("NewMySQLBucketStore error", err)
+			return err
+		}
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		counters, err := oram.NewCounterStore(bucketStore, key, cfg.ORAMCapacity)
+		if err != nil {
+			fmt.Println("NewCounterStore error", err)
+			return err
+		}
+		odxt.Counters = counters
+	}
+	switch cfg.Padding {
+	case "pow2":
+		odxt.Padding = ODXT.PadPowerOfTwo
+	case "step":
+		odxt.Padding, odxt.PadStep = ODXT.PadStep, cfg.PadStep
+	case "laplace":
+		odxt.Padding, odxt.Epsilon, odxt.Delta = ODXT.PadLaplace, cfg.Epsilon, cfg.Delta
+	}
+	odxt.MaxConjuncts = cfg.MaxConjuncts
+	if cfg.ValueEncoding == "aead" {
+		// 条目值使用 AES-GCM 编码，解密时丢弃被篡改的条目；需与生成密文时的设置一致
+		odxt.ValueEncoding = ODXT.AEADEncoding
+	}
+	var store ODXT.Store = &ODXT.MySQLStore{DB: odxt.MySQLDB, TableName: cfg.Db}
+	if cfg.Leakage {
+		// 服务器端观察到的查询和更新写入 result/Leakage/<Scheme>/ 下的 JSON-lines 文件
+		scheme := "ODXT"
+		if cfg.HXT {
+			scheme = "HXT"
+		}
+		path := filepath.Join("result", "Leakage", scheme, fmt.Sprintf("%s_%s.jsonl", cfg.Db, time.Now().Format("2006-01-02_15-04-05")))
+		recorder, err := leakage.NewRecorder(path)
+		if err != nil {
+			fmt.Println("NewRecorder error", err)
+			return err
+		}
+		defer recorder.Close()
+		odxt.Recorder = recorder
+		store = &ODXT.RecordingStore{Store: store, Recorder: recorder, Scheme: scheme}
+		odxt.Store = store
+	}
+	if cfg.EpochSize > 0 {
+		// 更新按固定大小的批次上传到 MySQL，epoch_interval 为空时只按数量上传
+		var interval time.Duration
+		if cfg.EpochInterval != "" {
+			var err error
+			if interval, err = time.ParseDuration(cfg.EpochInterval); err != nil {
+				fmt.Println("epoch_interval error", err)
+				return err
+			}
+		}
+		epochs := odxt.NewEpochStore(store, cfg.EpochSize, interval)
+		odxt.Store = epochs
+		if interval > 0 {
+			// 按固定间隔上传批次，与客户端的写入时间无关；退出前上传剩余的缓存
+			ticker := time.NewTicker(interval)
+			done := make(chan struct{})
+			go func() {
+				for {
+					select {
+					case now := <-ticker.C:
+						if err := epochs.Tick(now); err != nil {
+							log.Println("EpochStore.Tick error", err)
+						}
+					case <-done:
+						return
+					}
+				}
+			}()
+			defer func() {
+				ticker.Stop()
+				close(done)
+				if err := epochs.Flush(); err != nil {
+					log.Println("EpochStore.Flush error", err)
+				}
+			}()
+		}
+	}
+	if cfg.HXT {
+		// 加密当前 XSet，之后的更新和查询使用隐藏交叉标签模式
+		if err := odxt.EnableHXT(); err != nil {
+			fmt.Println("EnableHXT error", err)
+			return err
+		}
+	}
+	if cfg.Verify {
+		// 以当前的 XSet 和加密索引建立认证数据，之后的查询结果在客户端验证
+		if err := odxt.EnableVerification(cfg.Db); err != nil {
+			fmt.Println("EnableVerification error", err)
+			return err
+		}
+	}
 	if strings.Contains(cfg.Phase, "c") {
 		t1 := time.Now()
 		odxt.CiphertextGenPhase(cfg.Db)
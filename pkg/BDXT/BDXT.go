@@ -0,0 +1,278 @@
+package BDXT
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BDXT 后向隐私的动态连接查询方案，在 ODXT 的 TSet/XSet 之上增加：
+//
+//  1. 版本化关键字：所有 PRF 输入中的 w 替换为 w||Ver[w]，
+//     每次以 w 为 s-term 查询后，客户端在本地合并添加/删除，
+//     将存活的 id 以新版本重新加密写回，并删除旧版本的全部条目（Fides 式合并）。
+//     服务器之后再也无法把新条目与被删除的旧条目联系起来。
+//  2. 待合并删除集合：删除操作与添加一样只追加一条 TSet 条目，不修改 XSet，
+//     客户端记录尚未合并的 (w, id) 删除，在查询结果中过滤以 w 为 x-term 的已删除文档，
+//     因此服务器在查询时不会看到针对 x-term 的删除信息。该集合在 w 合并后清空。
+type BDXT struct {
+	ODXT.ODXT
+	// Ver 为每个关键字的当前版本
+	Ver map[string]int
+	// Pending 为尚未合并的删除 w -> ids
+	Pending map[string]map[string]struct{}
+}
+
+// Init 初始化客户端状态和 XSet，不连接任何数据库
+func (b *BDXT) Init(keys [4][]byte, store ODXT.Store) {
+	b.ODXT.Init(keys, store)
+	b.SkipDelXtags = true
+	b.Ver = make(map[string]int)
+	b.Pending = make(map[string]map[string]struct{})
+}
+
+// TableName 返回 BDXT 保存加密索引的数据表。合并会删除旧版本的条目，因此不能与 ODXT 共用 dbName 表
+func TableName(dbName string) string {
+	return dbName + "_bdxt"
+}
+
+func (b *BDXT) DBSetup(dbName string, randomKey bool) error {
+	if err := b.ODXT.DBSetup(dbName, randomKey); err != nil {
+		return err
+	}
+	if err := ODXT.CreateTable(b.MySQLDB, TableName(dbName)); err != nil {
+		return err
+	}
+	b.SkipDelXtags = true
+	b.Ver = make(map[string]int)
+	b.Pending = make(map[string]map[string]struct{})
+	return nil
+}
+
+// versioned 返回关键字当前版本在 ODXT 中使用的名称 w||ver
+func (b *BDXT) versioned(keyword string) string {
+	return fmt.Sprintf("%s\x00%d", keyword, b.Ver[keyword])
+}
+
+// Encrypt 为关键字 keyword 的 ids 生成添加或删除条目
+func (b *BDXT) Encrypt(keyword string, ids []string, operation int) (time.Duration, []ODXT.UpdatePayload, error) {
+	for _, id := range ids {
+		if utils.Operation(operation) == utils.Del {
+			if b.Pending[keyword] == nil {
+				b.Pending[keyword] = make(map[string]struct{})
+			}
+			b.Pending[keyword][id] = struct{}{}
+		} else {
+			delete(b.Pending[keyword], id)
+		}
+	}
+	return b.ODXT.Encrypt(b.versioned(keyword), ids, operation)
+}
+
+func (b *BDXT) CiphertextGenPhase(dbName string) {
+	// 获取MongoDB数据库
+	plaintextDB := b.PlaintextDB
+	defer plaintextDB.Client().Disconnect(context.Background())
+
+	// 读取所有记录
+	ctx := context.TODO()
+	opts := options.Find().SetNoCursorTimeout(true).SetBatchSize(1000)
+	cur, err := plaintextDB.Collection("id_keywords").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer cur.Close(ctx)
+	var keywordIds []bson.M
+	if err = cur.All(ctx, &keywordIds); err != nil {
+		log.Fatal(err)
+	}
+
+	resultData := make([][]string, 0, len(keywordIds))
+	for _, keywordId := range keywordIds {
+		valSet, ok := keywordId["val_set"].(primitive.A)
+		if !ok {
+			log.Fatal("val_set is not of type primitive.A")
+		}
+		var ids []string
+		for _, v := range valSet {
+			if str, ok := v.(string); ok {
+				ids = append(ids, str)
+			} else {
+				log.Fatal("val_set contains non-string value")
+			}
+		}
+		ids = utils.RemoveDuplicates(ids)
+		keyword := keywordId["k"].(string)
+
+		encryptTime, keywordCipher, err := b.Encrypt(keyword, ids, int(utils.Add))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = b.store(TableName(dbName)).Write(keywordCipher); err != nil {
+			log.Fatal(err)
+		}
+		resultData = append(resultData, []string{keyword, strconv.Itoa(len(keywordCipher)), encryptTime.String(), strconv.Itoa(ODXT.CalculateUpdatePayloadSize(keywordCipher))})
+	}
+
+	saveTime := time.Now()
+	// 保存 XSet 和客户端状态到文件
+	err = utils.SaveBloomFilterToFile(b.XSet, filepath.Join("result", "Update", "BDXT", fmt.Sprintf("%s_%s_XSet.bin", dbName, saveTime.Format("2006-01-02_15-04-05"))))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = utils.SaveJSONToFile(map[string]any{"UpdateCnt": b.UpdateCnt, "Ver": b.Ver}, filepath.Join("result", "Update", "BDXT", fmt.Sprintf("%s_%s_State.json", dbName, saveTime.Format("2006-01-02_15-04-05"))))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 将结果写入文件
+	resultpath := filepath.Join("result", "Update", "BDXT", fmt.Sprintf("%s_%s.csv", dbName, saveTime.Format("2006-01-02_15-04-05")))
+	resultHeader := []string{"keyword", "volume", "addTime", "storageUpdateBytes"}
+	err = utils.WriteResultToCSV(resultpath, resultHeader, resultData)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// store 返回保存加密索引的存储
+func (b *BDXT) store(tableName string) ODXT.Store {
+	if b.Store != nil {
+		return b.Store
+	}
+	return &ODXT.MySQLStore{DB: b.MySQLDB, TableName: tableName}
+}
+
+func (b *BDXT) SearchPhase(dbName, fileName string) {
+	tableName := TableName(dbName)
+	fileName = "./cmd/ODXT/" + fileName
+	keywordsList := utils.QueryKeywordsFromFile(fileName)
+
+	resultData := make([][]string, 0, len(keywordsList))
+	for _, keywords := range keywordsList {
+		res, err := b.Search(keywords, tableName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resultData = append(resultData, []string{strings.Join(keywords, "#"), res.ClientTime.String(), res.ServerTime.String(), strconv.Itoa(len(res.IDs)),
+			res.CompactTime.String(), strconv.Itoa(res.Compacted)})
+	}
+
+	resultpath := filepath.Join("result", "Search", "BDXT", fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
+	resultHeader := []string{"keyword", "clientSearchTime", "serverTime", "resultLength", "compactTime", "compactedEntries"}
+	err := utils.WriteResultToCSV(resultpath, resultHeader, resultData)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SearchResult 一次查询的结果和开销
+type SearchResult struct {
+	IDs         []string
+	ClientTime  time.Duration
+	ServerTime  time.Duration
+	CompactTime time.Duration // s-term 合并（重新加密并替换旧条目）的耗时
+	Compacted   int           // 合并后写回的条目数
+}
+
+// Search 执行连接查询，并在查询结束后合并 s-term 的条目
+func (b *BDXT) Search(q []string, tableName string) (*SearchResult, error) {
+	// 与 ODXT.Trapdoor 相同的规则选择 s-term
+	vq := make([]string, len(q))
+	counter, w1, vw1 := math.MaxInt64, q[0], b.versioned(q[0])
+	for i, w := range q {
+		vq[i] = b.versioned(w)
+		if num := b.UpdateCnt[vq[i]]; num < counter {
+			w1, vw1, counter = w, vq[i], num
+		}
+	}
+
+	trapdoorTime, serverTime, sEOpList, err := b.ODXT.Search(vq, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	ids, err := b.Decrypt(vq, sEOpList)
+	if err != nil {
+		return nil, err
+	}
+	// 过滤尚未合并的删除
+	sIdList := make([]string, 0, len(ids))
+	for _, id := range ids {
+		deleted := false
+		for _, w := range q {
+			if _, ok := b.Pending[w][id]; ok {
+				deleted = true
+				break
+			}
+		}
+		if !deleted {
+			sIdList = append(sIdList, id)
+		}
+	}
+
+	// 解密 s-term 的全部条目，得到存活的 id
+	all := make([]utils.SEOp, len(sEOpList))
+	for i, sEOp := range sEOpList {
+		all[i] = utils.SEOp{J: sEOp.J, Sval: sEOp.Sval, Cnt: 1}
+	}
+	alive, err := b.Decrypt([]string{vw1}, all)
+	if err != nil {
+		return nil, err
+	}
+	res := &SearchResult{IDs: sIdList, ClientTime: trapdoorTime + time.Since(start), ServerTime: serverTime}
+
+	// 合并会删除旧版本的全部条目，只有取回了 s-term 的全部条目时才能进行
+	if len(sEOpList) != counter {
+		log.Printf("skip compaction of %q: got %d entries, want %d", w1, len(sEOpList), counter)
+		return res, nil
+	}
+	start = time.Now()
+	if counter > 0 {
+		if err := b.compact(w1, alive, tableName); err != nil {
+			return nil, err
+		}
+		res.Compacted = len(alive)
+	}
+	res.CompactTime = time.Since(start)
+
+	return res, nil
+}
+
+// compact 以新版本重新加密 w 的存活 id，并删除旧版本的全部条目
+func (b *BDXT) compact(w string, alive []string, tableName string) error {
+	vw := b.versioned(w)
+	old := make([]string, b.UpdateCnt[vw])
+	for j := range old {
+		addr, err := b.Address(vw, j+1)
+		if err != nil {
+			return err
+		}
+		old[j] = addr
+	}
+
+	b.Ver[w]++
+	delete(b.UpdateCnt, vw)
+	delete(b.Pending, w)
+	if len(alive) > 0 {
+		_, payloads, err := b.ODXT.Encrypt(b.versioned(w), alive, int(utils.Add))
+		if err != nil {
+			return err
+		}
+		if err := b.store(tableName).Write(payloads); err != nil {
+			return err
+		}
+	}
+	return b.store(tableName).Delete(old)
+}
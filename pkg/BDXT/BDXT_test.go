@@ -0,0 +1,101 @@
+package BDXT
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"slices"
+	"testing"
+)
+
+func newTestBDXT(t *testing.T) (*BDXT, ODXT.MemoryStore) {
+	var keys [4][]byte
+	for i := range keys {
+		keys[i] = []byte("0123456789abcdef0123456789abcdef")
+	}
+	store := ODXT.NewMemoryStore()
+	b := &BDXT{}
+	b.Init(keys, store)
+	update := func(op utils.Operation, w string, ids ...string) {
+		_, payloads, err := b.Encrypt(w, ids, int(op))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Write(payloads); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update(utils.Add, "a", "id1", "id2", "id3")
+	update(utils.Add, "b", "id1", "id3", "id4")
+	update(utils.Del, "a", "id1")
+	update(utils.Del, "b", "id3")
+	return b, store
+}
+
+func TestCompaction(t *testing.T) {
+	b, store := newTestBDXT(t)
+	if len(store) != 8 {
+		t.Fatalf("store has %d entries, want 8", len(store))
+	}
+
+	// s-term 为 a，a 的删除被合并，b 的删除由 Pending 过滤
+	res, err := b.Search([]string{"a", "b"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.IDs) != 0 {
+		t.Errorf("Search(a, b) = %v, want []", res.IDs)
+	}
+	if res.Compacted != 2 || b.Ver["a"] != 1 || len(store) != 6 {
+		t.Errorf("compacted %d entries, ver %d, store %d; want 2, 1, 6", res.Compacted, b.Ver["a"], len(store))
+	}
+
+	res, err = b.Search([]string{"b"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(res.IDs)
+	if want := []string{"id1", "id4"}; !slices.Equal(res.IDs, want) {
+		t.Errorf("Search(b) = %v, want %v", res.IDs, want)
+	}
+	if len(b.Pending["b"]) != 0 || len(store) != 4 {
+		t.Errorf("pending %v, store %d; want empty, 4", b.Pending["b"], len(store))
+	}
+
+	res, err = b.Search([]string{"a"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(res.IDs)
+	if want := []string{"id2", "id3"}; !slices.Equal(res.IDs, want) {
+		t.Errorf("Search(a) = %v, want %v", res.IDs, want)
+	}
+}
+
+func TestDeleteLeavesXSet(t *testing.T) {
+	b, _ := newTestBDXT(t)
+	xSet := b.XSet.Copy()
+	if _, _, err := b.Encrypt("a", []string{"id2"}, int(utils.Del)); err != nil {
+		t.Fatal(err)
+	}
+	if !b.XSet.Equal(xSet) {
+		t.Error("delete modified the XSet")
+	}
+}
+
+func TestCompactionSkippedOnFailedFetch(t *testing.T) {
+	b, store := newTestBDXT(t)
+	vw := b.versioned("a")
+	addr, err := b.Address(vw, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(store, addr)
+
+	if _, err := b.Search([]string{"a"}, ""); err == nil {
+		t.Fatal("Search with a missing entry succeeded, want error")
+	}
+	// 读取失败时不合并，其余条目保持不变
+	if b.Ver["a"] != 0 || b.UpdateCnt[vw] != 4 || len(store) != 7 {
+		t.Errorf("ver %d, count %d, store %d; want 0, 4, 7", b.Ver["a"], b.UpdateCnt[vw], len(store))
+	}
+}
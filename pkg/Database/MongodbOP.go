@@ -133,3 +133,24 @@ func GetUniqueValSets(PlaintextDB *mongo.Database) ([]string, error) {
 
 	return uniqueVals, nil
 }
+
+// GetUniqueIDs 获取 id_keywords 集合中所有去重后的文档 id
+func GetUniqueIDs(PlaintextDB *mongo.Database) ([]string, error) {
+	collection := PlaintextDB.Collection("id_keywords")
+	ctx := context.TODO()
+
+	results, err := collection.Distinct(ctx, "k", bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 将结果转换为字符串切片
+	var uniqueIDs []string
+	for _, result := range results {
+		if id, ok := result.(string); ok {
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+
+	return uniqueIDs, nil
+}
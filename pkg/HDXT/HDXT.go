@@ -2,6 +2,8 @@ package HDXT
 
 import (
 	"ConjunctiveSSE/pkg/Database"
+	"ConjunctiveSSE/pkg/auhme"
+	"ConjunctiveSSE/pkg/leakage"
 	"ConjunctiveSSE/pkg/utils"
 	"context"
 	"crypto/rand"
@@ -23,24 +25,108 @@ import (
 
 const (
 	UploadListMaxLength = 200000
+	// AuhmeDelta AUHME 编辑缓存的容量，0 表示每次编辑都立即下发
+	AuhmeDelta = 0
 )
 
+// SetupMode AUHME 条目的生成方式
+type SetupMode int
+
+const (
+	// Dense 为每个文档生成全部 |W| 个 AUHME 条目，服务器只能看到 |W|
+	Dense SetupMode = iota
+	// Sparse 只为文档中实际出现的 (w, id) 生成条目，缺失的条目在查询时视为 0，
+	// 每个文档的条目数向上填充到 PadStep 的整数倍，服务器可以看到填充后的文档大小
+	Sparse
+	// MitraOnly 不生成 AUHME 条目，查询时对每个关键字执行 Mitra 单关键字查询并在客户端求交，
+	// 作为朴素的对比基线，服务器可以看到查询中每个关键字的结果数量
+	MitraOnly
+)
+
+func (m SetupMode) String() string {
+	switch m {
+	case Sparse:
+		return "sparse"
+	case MitraOnly:
+		return "naive"
+	}
+	return "dense"
+}
+
+// Name 返回当前模式对应的方案名称，用作结果目录名
+func (hdxt *HDXT) Name() string {
+	if hdxt.Mode == MitraOnly {
+		return "MitraNaive"
+	}
+	return "HDXT"
+}
+
 type Mitra struct {
 	Key     []byte
 	FileCnt map[string]int
 }
 
-type Auhme struct {
-	Keys   [3][]byte
-	Deltas *Delta
-}
-
 type HDXT struct {
 	PlaintextDB     *mongo.Database
 	MitraCipherList map[string]string
-	AuhmeCipherList map[string]string
+	AuhmeServer     *auhme.Server
 	Mitra
-	Auhme
+	Auhme *auhme.Client
+	// UniverseKeywords 和 UniverseIDs 为数据集中的所有关键字和文档 id
+	UniverseKeywords []string
+	UniverseIDs      []string
+	// Mode 和 PadStep 控制 AUHME 条目的生成方式，需在 SetupPhase 之前设置
+	Mode    SetupMode
+	PadStep int
+	// FileCntStore 不为 nil 时 FileCnt 保存在其中（如 oram.Map），FileCnt 只作为本次操作的缓存
+	FileCntStore FileCntStore
+	// Recorder 不为 nil 时记录服务器端观察到的 Mitra 地址和 AUHME 标签
+	Recorder   *leakage.Recorder
+	keywordSet map[string]struct{}
+	idSet      map[string]struct{}
+}
+
+// FileCntStore Mitra 计数的外部存储
+type FileCntStore interface {
+	GetInt(key string) (int, error)
+	PutInt(key string, v int) error
+}
+
+// loadFileCnt 从 FileCntStore 读取 keywords 的计数，计数为 0 的关键字从 FileCnt 中删除
+func (hdxt *HDXT) loadFileCnt(keywords ...string) error {
+	if hdxt.FileCntStore == nil {
+		return nil
+	}
+	for _, w := range keywords {
+		cnt, err := hdxt.FileCntStore.GetInt(w)
+		if err != nil {
+			return err
+		}
+		if cnt == 0 {
+			delete(hdxt.FileCnt, w)
+		} else {
+			hdxt.FileCnt[w] = cnt
+		}
+	}
+	return nil
+}
+
+// storeFileCnt 将 keyword 的计数写回 FileCntStore
+func (hdxt *HDXT) storeFileCnt(keyword string) error {
+	if hdxt.FileCntStore == nil {
+		return nil
+	}
+	return hdxt.FileCntStore.PutInt(keyword, hdxt.FileCnt[keyword])
+}
+
+// ClientState 客户端的可持久化状态
+type ClientState struct {
+	FileCnt          map[string]int
+	UniverseKeywords []string
+	UniverseIDs      []string
+	Mode             SetupMode
+	PadStep          int
+	Auhme            *auhme.State
 }
 
 type MitraCipherText struct {
@@ -53,14 +139,38 @@ type AuhmeCipherText struct {
 	Enc   string
 }
 
-var (
-	universeKeywordsNums int
-	universeKeywords     []string
-	universeIDs          []string
-	universeIDsNums      int
-)
-
 func (hdxt *HDXT) Init(dbName string, randomKey bool) error {
+	err := hdxt.InitClient(randomKey)
+	if err != nil {
+		return err
+	}
+
+	// 连接MongoDB
+	hdxt.PlaintextDB, err = Database.MongoDBSetup(dbName)
+	if err != nil {
+		log.Println("Error initializing PlaintextDB:", err)
+		return err
+	}
+
+	// 获取所有keyword和id
+	hdxt.UniverseKeywords, err = Database.GetUniqueValSets(hdxt.PlaintextDB)
+	if err != nil {
+		log.Println("Error getting UniverseKeywords:", err)
+		return err
+	}
+	hdxt.UniverseIDs, err = Database.GetUniqueIDs(hdxt.PlaintextDB)
+	if err != nil {
+		log.Println("Error getting UniverseIDs:", err)
+		return err
+	}
+	hdxt.indexUniverse()
+
+	return nil
+}
+
+// InitClient 初始化密钥、客户端状态和内存中的服务器，不连接 MongoDB，
+// 关键字和文档全集随 Setup/Encrypt 动态增长
+func (hdxt *HDXT) InitClient(randomKey bool) error {
 	// 初始化私钥
 	if randomKey {
 		// 生成4个16字节长度的随机私钥
@@ -70,48 +180,29 @@ func (hdxt *HDXT) Init(dbName string, randomKey bool) error {
 			log.Println("Error generating random key:", err)
 			return err
 		}
-		for i := 0; i < 3; i++ {
-			key := make([]byte, keyLen)
-			if _, err := rand.Read(key); err != nil {
-				log.Println("Error generating random key:", err)
-				return err
-			}
-			hdxt.Auhme.Keys[i] = key
+		var err error
+		hdxt.Auhme, err = auhme.KeyGen(AuhmeDelta)
+		if err != nil {
+			log.Println("Error generating random key:", err)
+			return err
 		}
 	} else {
 		// 读取私钥
-		var err error
-		hdxt.Mitra.Key, hdxt.Auhme.Keys, err = utils.HdxtReadKeys("./cmd/HDXT/keys.txt")
+		mitraKey, auhmeKeys, err := utils.HdxtReadKeys("./cmd/HDXT/keys.txt")
 		if err != nil {
 			log.Println("Error reading keys:", err)
 			return err
 		}
+		hdxt.Mitra.Key = mitraKey
+		hdxt.Auhme = auhme.NewClient(auhmeKeys, AuhmeDelta)
 	}
 
 	// 初始化FileCnt
 	hdxt.Mitra.FileCnt = make(map[string]int)
 
-	var err error
-	// 连接MongoDB
-	hdxt.PlaintextDB, err = Database.MongoDBSetup(dbName)
-	if err != nil {
-		log.Println("Error initializing PlaintextDB:", err)
-		return err
-	}
-
-	// 获取keyword数量
-	universeKeywords, err = Database.GetUniqueValSets(hdxt.PlaintextDB)
-	if err != nil {
-		log.Println("Error getting universeKeywords:", err)
-		return err
-	}
-	universeKeywordsNums = len(universeKeywords)
-
-	// 初始化Auhme
-	hdxt.Auhme.Deltas = &Delta{cnt: 0, t: make(map[string]int), delta: 0, s: make([]string, 0)}
-
 	hdxt.MitraCipherList = make(map[string]string)
-	hdxt.AuhmeCipherList = make(map[string]string)
+	hdxt.AuhmeServer = auhme.NewServer()
+	hdxt.indexUniverse()
 
 	return nil
 }
@@ -121,6 +212,20 @@ type volume struct {
 	auhmeVolume int
 }
 
+// docStats 记录一个文档写入时的全集扩展开销和 AUHME 条目数
+type docStats struct {
+	growthTime    time.Duration
+	growthEntries int
+	auhmeEntries  int
+	dummyEntries  int
+}
+
+var (
+	// mitraEntrySize 和 auhmeEntrySize 为单条密文在服务器上占用的字节数
+	mitraEntrySize = 2 * base64.StdEncoding.EncodedLen(32)
+	auhmeEntrySize = 2 * len(auhme.Block{})
+)
+
 func (hdxt *HDXT) SetupPhase() error {
 	// 获取MongoDB数据库
 	plaintextDB := hdxt.PlaintextDB
@@ -128,9 +233,10 @@ func (hdxt *HDXT) SetupPhase() error {
 
 	// 初始化
 	encryptTimeList := make([]time.Duration, 0, 1000000)
-	tokenList := make([]*UTok, 0, 1000000)
+	tokenList := make([]*auhme.UpdateToken, 0, 1000000)
 	idList := make([]string, 0, 1000000)
 	volumeList := make([]volume, 0, 1000000)
+	statsList := make([]docStats, 0, 1000000)
 
 	// 从MongoDB数据库中获取名为"id_keywords"的集合
 	collection := plaintextDB.Collection("id_keywords")
@@ -173,15 +279,16 @@ func (hdxt *HDXT) SetupPhase() error {
 		keywords = utils.RemoveDuplicates(keywords) // 对keywords去重
 		id := idKeyword["k"].(string)
 
-		encryptTime, err := hdxt.Setup(id, keywords, 1)
+		encryptTime, stats, err := hdxt.Setup(id, keywords, int(utils.Add))
 		if err != nil {
 			log.Println("Error in Setup:", err)
 			return err
 		}
+		statsList = append(statsList, stats)
 
 		encryptTimeList = append(encryptTimeList, encryptTime)
 		idList = append(idList, id)
-		volumeList = append(volumeList, volume{mitraVolume: len(hdxt.MitraCipherList), auhmeVolume: len(hdxt.AuhmeCipherList)})
+		volumeList = append(volumeList, volume{mitraVolume: len(hdxt.MitraCipherList), auhmeVolume: len(hdxt.AuhmeServer.EDB)})
 	}
 
 	// Update Phase
@@ -202,42 +309,51 @@ func (hdxt *HDXT) SetupPhase() error {
 		}
 		keywords = utils.RemoveDuplicates(keywords) // 对keyword去重
 		id := idKeyword["k"].(string)
-		encryptTime, tokList, err := hdxt.Encrypt(id, keywords, 1)
+		encryptTime, stats, tokList, err := hdxt.Encrypt(id, keywords, utils.Add)
 		if err != nil {
 			log.Println("Error in Encrypt:", err)
 			return err
 		}
+		statsList = append(statsList, stats)
 
 		// server update
 		for _, tok := range tokList {
-			auhmeApplyUpd(hdxt, tok)
+			hdxt.AuhmeServer.ApplyUpd(tok)
 		}
 
 		// save to []
 		encryptTimeList = append(encryptTimeList, encryptTime)
 		tokenList = append(tokenList, tokList...)
 		idList = append(idList, id)
-		volumeList = append(volumeList, volume{mitraVolume: len(hdxt.MitraCipherList), auhmeVolume: len(hdxt.AuhmeCipherList)})
+		volumeList = append(volumeList, volume{mitraVolume: len(hdxt.MitraCipherList), auhmeVolume: len(hdxt.AuhmeServer.EDB)})
 	}
 	saveTime := time.Now()
 
-	// 保存 odxt.UpdateCnt 到文件
-	err = utils.SaveUpdateCntToFile(hdxt.FileCnt, filepath.Join("result", "Update", "HDXT", fmt.Sprintf("%s_UpdateCnt.json", saveTime.Format("2006-01-02_15-04-05"))))
+	// 保存客户端状态到文件
+	err = hdxt.SaveClientState(filepath.Join("result", "Update", hdxt.Name(), fmt.Sprintf("%s_ClientState.json", saveTime.Format("2006-01-02_15-04-05"))))
 	if err != nil {
-		log.Println("Error saving UpdateCnt to file:", err)
+		log.Println("Error saving client state to file:", err)
 		return err
 	}
 
 	// 设置结果文件的路径和名称
-	resultpath := filepath.Join("result", "Update", "HDXT", fmt.Sprintf("%s.csv", saveTime.Format("2006-01-02_15-04-05")))
+	resultpath := filepath.Join("result", "Update", hdxt.Name(), fmt.Sprintf("%s.csv", saveTime.Format("2006-01-02_15-04-05")))
 
 	// 定义结果表头
-	resultHeader := []string{"keyword", "volume", "addTime", "storageUpdateBytes"}
+	// observedEntries 为服务器观察到的该文档条目数（含填充），即稀疏模式下泄露的文档大小
+	resultHeader := []string{"keyword", "volume", "addTime", "storageUpdateBytes", "growthTime", "growthEntries", "mode", "auhmeEntries", "dummyEntries", "observedEntries"}
 
 	// 将结果数据整理成表格形式
 	resultData := make([][]string, len(idList))
+	prev := volume{}
 	for i, id := range idList {
-		resultData[i] = []string{id, strconv.Itoa(volumeList[i].mitraVolume + volumeList[i].auhmeVolume), encryptTimeList[i].String()}
+		cur := volumeList[i]
+		storageUpdateBytes := (cur.mitraVolume-prev.mitraVolume)*mitraEntrySize + (cur.auhmeVolume-prev.auhmeVolume)*auhmeEntrySize
+		stats := statsList[i]
+		resultData[i] = []string{id, strconv.Itoa(cur.mitraVolume + cur.auhmeVolume), encryptTimeList[i].String(), strconv.Itoa(storageUpdateBytes),
+			stats.growthTime.String(), strconv.Itoa(stats.growthEntries), hdxt.Mode.String(),
+			strconv.Itoa(stats.auhmeEntries), strconv.Itoa(stats.dummyEntries), strconv.Itoa(stats.auhmeEntries + stats.dummyEntries)}
+		prev = cur
 	}
 
 	// 将结果写入文件
@@ -250,146 +366,306 @@ func (hdxt *HDXT) SetupPhase() error {
 	return nil
 }
 
-func (hdxt *HDXT) Setup(id string, keywords []string, operation int) (time.Duration, error) {
-	var encryptedTime time.Duration
+func (hdxt *HDXT) Setup(id string, keywords []string, operation int) (time.Duration, docStats, error) {
+	hdxt.Recorder.Begin(hdxt.Name(), "update")
+	defer hdxt.endRecord()
 
-	for _, keyword := range universeKeywords {
-		if slices.Contains(keywords, keyword) {
-			if _, ok := hdxt.FileCnt[keyword]; !ok {
-				hdxt.FileCnt[keyword] = 0
-			}
-			start := time.Now()
+	// 扩展关键字和文档全集，新关键字的条目直接写入服务器
+	utok, growthTime, err := hdxt.GrowUniverse(id, keywords)
+	if err != nil {
+		log.Println(err)
+		return 0, docStats{}, err
+	}
+	stats := docStats{growthTime: growthTime}
+	if utok != nil {
+		for label, enc := range utok.Tok {
+			hdxt.AuhmeServer.Set(label, enc)
+		}
+		hdxt.recordTokens(utok)
+		stats.growthEntries = len(utok.Tok)
+	}
 
-			// OXT Part
-			address, val, err := mitraEncrypt(hdxt, keyword, id, operation)
-			if err != nil {
-				log.Println(err)
-				return 0, err
-			}
+	start := time.Now()
+	entries, dummies, err := hdxt.encryptDocument(id, keywords, operation)
+	if err != nil {
+		log.Println(err)
+		return 0, docStats{}, err
+	}
+	encryptedTime := time.Since(start)
 
-			// Auhme Part
-			label, enc, err := auhmeEncrypt(hdxt, keyword, id, 1, 0)
+	for label, enc := range entries {
+		hdxt.AuhmeServer.Set(label, enc)
+	}
+	hdxt.recordTokens(&auhme.UpdateToken{Tok: entries})
+	stats.auhmeEntries, stats.dummyEntries = len(entries)-dummies, dummies
+
+	return encryptedTime, stats, nil
+}
+
+// encryptDocument 生成文档 id 的 Mitra 密文（直接写入服务器）和 AUHME 条目。
+// Dense 模式遍历整个关键字全集；Sparse 模式只加密 keywords 并补充随机填充条目。
+// 返回 AUHME 条目及其中填充条目的数量。
+func (hdxt *HDXT) encryptDocument(id string, keywords []string, operation int) (map[auhme.Block]auhme.Block, int, error) {
+	mitra := func(keyword string) error {
+		if _, ok := hdxt.FileCnt[keyword]; !ok {
+			hdxt.FileCnt[keyword] = 0
+		}
+		address, val, err := mitraEncrypt(hdxt, keyword, id, operation)
+		if err != nil {
+			return err
+		}
+		hdxt.MitraCipherList[address] = val
+		hdxt.recordAddress(address)
+		return nil
+	}
+
+	entries := make(map[auhme.Block]auhme.Block)
+	if hdxt.Mode == MitraOnly {
+		for _, keyword := range keywords {
+			if err := mitra(keyword); err != nil {
+				return nil, 0, err
+			}
+		}
+		return entries, 0, nil
+	}
+	if hdxt.Mode == Sparse {
+		for _, keyword := range keywords {
+			if err := mitra(keyword); err != nil {
+				return nil, 0, err
+			}
+			label, enc, err := hdxt.Auhme.Encrypt(keyword+id, 1)
 			if err != nil {
-				log.Println(err)
-				return 0, err
+				return nil, 0, err
 			}
+			entries[label] = enc
+		}
 
-			encryptedTime += time.Since(start)
-			hdxt.AuhmeCipherList[label] = enc
-			hdxt.MitraCipherList[address] = val
-		} else {
-			start := time.Now()
-			// Auhme Part
-			label, enc, err := auhmeEncrypt(hdxt, keyword, id, 0, 0)
+		// 填充到 PadStep 的整数倍
+		dummies := 0
+		if hdxt.PadStep > 1 && len(keywords)%hdxt.PadStep != 0 {
+			dummies = hdxt.PadStep - len(keywords)%hdxt.PadStep
+		}
+		for i := 0; i < dummies; i++ {
+			label, enc, err := hdxt.Auhme.Dummy()
 			if err != nil {
-				log.Println(err)
-				return 0, err
+				return nil, 0, err
 			}
+			entries[label] = enc
+		}
+		return entries, dummies, nil
+	}
 
-			encryptedTime += time.Since(start)
-			hdxt.AuhmeCipherList[label] = enc
+	for _, keyword := range hdxt.UniverseKeywords {
+		v := 0
+		if slices.Contains(keywords, keyword) {
+			if err := mitra(keyword); err != nil {
+				return nil, 0, err
+			}
+			v = 1
 		}
+		label, enc, err := hdxt.Auhme.Encrypt(keyword+id, v)
+		if err != nil {
+			return nil, 0, err
+		}
+		entries[label] = enc
+	}
+	return entries, 0, nil
+}
+
+// Encrypt 以 operation 更新文档 id 的 keywords，utils.Del 等同于 Delete
+func (hdxt *HDXT) Encrypt(id string, keywords []string, operation utils.Operation) (time.Duration, docStats, []*auhme.UpdateToken, error) {
+	switch operation {
+	case utils.Add:
+	case utils.Del:
+		deleteTime, tokList, err := hdxt.Delete(id, keywords)
+		return deleteTime, docStats{}, tokList, err
+	default:
+		return 0, docStats{}, nil, fmt.Errorf("unsupported operation %d", operation)
 	}
 
-	return encryptedTime, nil
+	hdxt.Recorder.Begin(hdxt.Name(), "update")
+	defer hdxt.endRecord()
+
+	tokList := make([]*auhme.UpdateToken, 0)
+	var stats docStats
+	// 扩展关键字和文档全集，单独统计其开销
+	utok, growthTime, err := hdxt.GrowUniverse(id, keywords)
+	if err != nil {
+		log.Println("Error in GrowUniverse:", err)
+		return 0, docStats{}, nil, err
+	}
+	stats.growthTime = growthTime
+	if utok != nil {
+		stats.growthEntries = len(utok.Tok)
+		tokList = append(tokList, utok)
+	}
+	start := time.Now()
+
+	entries, dummies, err := hdxt.encryptDocument(id, keywords, int(utils.Add))
+	if err != nil {
+		log.Println("Error in Encrypt:", err)
+		return 0, docStats{}, nil, err
+	}
+	stats.auhmeEntries, stats.dummyEntries = len(entries)-dummies, dummies
+	tokList = append(tokList, &auhme.UpdateToken{Tok: entries, Op: auhme.Add})
+	encryptedTime := time.Since(start)
+	hdxt.recordTokens(tokList...)
+	return encryptedTime, stats, tokList, nil
 }
 
-func (hdxt *HDXT) Encrypt(id string, keywords []string, operation Operation) (time.Duration, []*UTok, error) {
-	tokList := make([]*UTok, 0)
-	UT := make(map[string]string)
-	var (
-		utok *UTok
-		del  *Delta
-		err  error
-	)
+// Delete 从索引中删除文档 id 的 keywords。
+// Mitra 部分为每个关键字追加一条删除密文（直接写入服务器），查询时由客户端合并；
+// AUHME 部分将 (w, id) 置 0，返回的更新令牌需由调用方下发到服务器。
+// 不存在或已删除的 (w, id) 被跳过，否则 AUHME 的翻转会把它置为 1。
+func (hdxt *HDXT) Delete(id string, keywords []string) (time.Duration, []*auhme.UpdateToken, error) {
+	hdxt.Recorder.Begin(hdxt.Name(), "update")
+	defer hdxt.endRecord()
+
+	tokList := make([]*auhme.UpdateToken, 0, len(keywords))
 	start := time.Now()
-	// op == add
-	if operation == Add {
-		for _, keyword := range universeKeywords {
-			if slices.Contains(keywords, keyword) {
-				if _, ok := hdxt.FileCnt[keyword]; !ok {
-					hdxt.FileCnt[keyword] = 0
-				}
-				// oxt part
-				address, val, err := mitraEncrypt(hdxt, keyword, id, int(operation))
-				if err != nil {
-					log.Println("Error in Encrypt:", err)
-					return 0, nil, err
-				}
-				hdxt.MitraCipherList[address] = val
-
-				// auhme part
-				utok, del, err = auhmeGenUpd(hdxt, Add, keyword+id, 1)
-				if err != nil {
-					log.Println("Error in auhmeGenUpd:", err)
-					return 0, nil, err
-				}
-				hdxt.Auhme.Deltas = del
-			} else {
-				// auhme part
-				utok, del, err = auhmeGenUpd(hdxt, Add, keyword+id, 0)
-				if err != nil {
-					log.Println("Error in auhmeGenUpd:", err)
-					return 0, nil, err
-				}
-				hdxt.Auhme.Deltas = del
-			}
-			ut := utok.tok
-			for k, v := range ut {
-				UT[k] = v
-			}
+	for _, keyword := range keywords {
+		if err := hdxt.loadFileCnt(keyword); err != nil {
+			return 0, nil, err
 		}
-		tokx := &UTok{tok: UT, op: Add}
-		tokList = append(tokList, tokx)
-	} else {
-		// op == edit
-		for _, keyword := range keywords {
-			tok, del, err := hdxt.EditPair(hdxt.Auhme.Deltas, id, keyword, operation)
+		if _, ok := hdxt.FileCnt[keyword]; !ok {
+			continue
+		}
+		if hdxt.Mode != MitraOnly {
+			v, err := hdxt.Auhme.Value(keyword + id)
 			if err != nil {
-				log.Println("Error in Encrypt:", err)
+				log.Println("Error in Delete:", err)
 				return 0, nil, err
 			}
-			if tok != nil {
-				tokList = append(tokList, tok)
+			if v == 0 {
+				continue
 			}
-			hdxt.Auhme.Deltas = del
+		}
+		address, val, err := mitraEncrypt(hdxt, keyword, id, int(utils.Del))
+		if err != nil {
+			log.Println("Error in Delete:", err)
+			return 0, nil, err
+		}
+		hdxt.MitraCipherList[address] = val
+		hdxt.recordAddress(address)
+
+		tok, err := hdxt.EditPair(id, keyword, utils.Del)
+		if err != nil {
+			log.Println("Error in Delete:", err)
+			return 0, nil, err
+		}
+		if tok != nil {
+			tokList = append(tokList, tok)
 		}
 	}
-	encryptedTime := time.Since(start)
-	return encryptedTime, tokList, nil
+	deleteTime := time.Since(start)
+	hdxt.recordTokens(tokList...)
+	return deleteTime, tokList, nil
 }
 
-func (hdxt *HDXT) EditPair(del *Delta, id, keyword string, operation Operation) (*UTok, *Delta, error) {
-	t, delta, s := del.t, del.delta, del.s
-	if len(t)+1 >= delta {
-		s = make([]string, 0)
-		for _, keyword := range universeKeywords {
-			for _, id := range universeIDs {
-				enc, err := utils.FAesni(hdxt.Auhme.Keys[0], []byte(keyword+id), 1)
-				if err != nil {
-					log.Println("Error in EditPair:", err)
-					return nil, nil, err
-				}
-				s = append(s, base64.StdEncoding.EncodeToString(enc))
-			}
-		}
+// EditPair 修改 (keyword, id) 在 AUHME 中的值，utils.Add 置 1，utils.Del 置 0
+func (hdxt *HDXT) EditPair(id, keyword string, operation utils.Operation) (*auhme.UpdateToken, error) {
+	v := 0
+	if operation == utils.Add {
+		v = 1
+	}
+	if hdxt.Mode == MitraOnly {
+		return nil, nil
 	}
-	del.s = s
-	if operation == EditPlus {
-		utok, al, err := auhmeGenUpd(hdxt, Edit, keyword+id, 1)
+
+	// 稀疏模式下缺失的条目视为 0，置 1 时直接新增条目
+	if hdxt.Mode == Sparse {
+		ok, err := hdxt.Auhme.Contains(keyword + id)
 		if err != nil {
 			log.Println("Error in EditPair:", err)
-			return nil, nil, err
+			return nil, err
 		}
-		return utok, al, nil
-	} else {
-		utok, al, err := auhmeGenUpd(hdxt, Edit, keyword+id, 0)
-		if err != nil {
-			log.Println("Error in EditPair:", err)
-			return nil, nil, err
+		if !ok {
+			if v == 0 {
+				return nil, nil
+			}
+			utok, err := hdxt.Auhme.GenUpd(auhme.Add, keyword+id, v)
+			if err != nil {
+				log.Println("Error in EditPair:", err)
+				return nil, err
+			}
+			return utok, nil
 		}
-		return utok, al, nil
 	}
+
+	utok, err := hdxt.Auhme.GenUpd(auhme.Edit, keyword+id, v)
+	if err != nil {
+		log.Println("Error in EditPair:", err)
+		return nil, err
+	}
+	return utok, nil
+}
+
+// SaveClientState 保存 FileCnt、关键字/文档全集和 AUHME 客户端状态到文件
+func (hdxt *HDXT) SaveClientState(filename string) error {
+	st := ClientState{
+		FileCnt:          hdxt.FileCnt,
+		UniverseKeywords: hdxt.UniverseKeywords,
+		UniverseIDs:      hdxt.UniverseIDs,
+		Mode:             hdxt.Mode,
+		PadStep:          hdxt.PadStep,
+		Auhme:            hdxt.Auhme.State(),
+	}
+	return utils.SaveJSONToFile(&st, filename)
+}
+
+// LoadClientState 从文件恢复客户端状态，需在 Init 之后调用
+func (hdxt *HDXT) LoadClientState(filename string) error {
+	var st ClientState
+	if err := utils.LoadJSONFromFile(&st, filename); err != nil {
+		return err
+	}
+	hdxt.FileCnt = st.FileCnt
+	if hdxt.FileCnt == nil {
+		hdxt.FileCnt = make(map[string]int)
+	}
+	hdxt.UniverseKeywords = st.UniverseKeywords
+	hdxt.UniverseIDs = st.UniverseIDs
+	hdxt.Mode, hdxt.PadStep = st.Mode, st.PadStep
+	hdxt.indexUniverse()
+	if st.Auhme != nil {
+		hdxt.Auhme.Restore(st.Auhme)
+	}
+	return nil
+}
+
+// SearchTimes 记录一次连接查询中各步骤的耗时
+type SearchTimes struct {
+	MitraTrapdoor time.Duration // Mitra 陷门生成（客户端）
+	MitraServer   time.Duration // Mitra 服务器查找
+	MitraDecrypt  time.Duration // Mitra 解密（客户端）
+	AuhmeGenKey   time.Duration // AUHME 查询密钥生成（客户端）
+	AuhmeServer   time.Duration // AUHME 服务器查询
+	Filter        time.Duration // 根据服务器返回的位置筛选最终结果（客户端）
+	AuhmeKeys     int           // 发送给服务器的 AUHME 查询密钥数量
+	// KeywordVolumes 为服务器在 Mitra 查询中看到的每个关键字的结果数量，
+	// HDXT 只包含 s-term，MitraOnly 包含查询中的全部关键字
+	KeywordVolumes map[string]int
+}
+
+// volumes 将 KeywordVolumes 按查询顺序格式化为 w:n#w:n
+func (st SearchTimes) volumes(keywords []string) string {
+	vols := make([]string, 0, len(st.KeywordVolumes))
+	for _, w := range keywords {
+		if n, ok := st.KeywordVolumes[w]; ok {
+			vols = append(vols, fmt.Sprintf("%s:%d", w, n))
+		}
+	}
+	return strings.Join(vols, "#")
+}
+
+// ClientTime 返回客户端总耗时
+func (st SearchTimes) ClientTime() time.Duration {
+	return st.MitraTrapdoor + st.MitraDecrypt + st.AuhmeGenKey + st.Filter
+}
+
+// ServerTime 返回服务器总耗时
+func (st SearchTimes) ServerTime() time.Duration {
+	return st.MitraServer + st.AuhmeServer
 }
 
 func (hdxt *HDXT) SearchPhase(tableName, fileName string) {
@@ -398,69 +674,35 @@ func (hdxt *HDXT) SearchPhase(tableName, fileName string) {
 
 	// 初始化结果列表
 	resultList := make([][]string, 0, len(keywordsList)+1)
-	clientSearchTime := make([]time.Duration, 0, len(keywordsList)+1)
-	serverTimeList := make([]time.Duration, 0, len(keywordsList)+1)
-	resultLengthList := make([]int, 0, len(keywordsList)+1)
-
-	clientTimeTotal := time.Duration(0)
-	serverTimeTotal := time.Duration(0)
+	timesList := make([]SearchTimes, 0, len(keywordsList)+1)
 
 	// 循环搜索
 	for _, keywords := range keywordsList {
-		// 单关键词搜索
-		// 选择查询频率最低的关键字
-		counter, w1 := math.MaxInt64, keywords[0]
-		for _, w := range keywords {
-			num := hdxt.FileCnt[w]
-			if num < counter {
-				w1 = w
-				counter = num
-			}
-		}
-		trapdoorTime, serverTime, w1Ids, err := hdxt.SearchOneKeyword(w1)
+		sIdList, times, err := hdxt.Search(keywords)
 		if err != nil {
 			log.Fatal(err)
 		}
-		clientTimeTotal += trapdoorTime
-		serverTimeTotal += serverTime
-
-		// auhme part
-		// clien search step 1
-		q := utils.RemoveElement(keywords, w1)
-		start := time.Now()
-		dkList, err := auhmeClientSearchStep1(hdxt, w1Ids, q)
-		if err != nil {
-			log.Fatal(err)
-		}
-		clientTimeTotal += time.Since(start)
-
-		// server search step
-		start = time.Now()
-		posList := auhmeServerSearch(hdxt, dkList)
-		serverTimeTotal += time.Since(start)
-
-		// client search step 2
-		start = time.Now()
-		sIdList := auhmeClientSearchStep2(w1Ids, posList)
-		clientTimeTotal += time.Since(start)
 
 		// 将结果添加到结果列表
 		resultList = append(resultList, sIdList)
-		clientSearchTime = append(clientSearchTime, clientTimeTotal)
-		serverTimeList = append(serverTimeList, serverTimeTotal)
-		resultLengthList = append(resultLengthList, len(sIdList))
+		timesList = append(timesList, times)
 	}
 
 	// 设置结果文件的路径和名称
-	resultpath := filepath.Join("result", "Search", "HDXT", fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
+	resultpath := filepath.Join("result", "Search", hdxt.Name(), fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
 
 	// 定义结果表头
-	resultHeader := []string{"keyword", "clientTime", "serverTime", "resultLength"}
+	resultHeader := []string{"keyword", "clientTime", "serverTime", "mitraTrapdoorTime", "mitraServerTime", "mitraDecryptTime",
+		"auhmeGenKeyTime", "auhmeServerTime", "filterTime", "auhmeKeys", "resultLength", "keywordVolumes"}
 
 	// 将结果数据整理成表格形式
 	resultData := make([][]string, len(resultList))
 	for i, keywords := range keywordsList {
-		resultData[i] = []string{strings.Join(keywords, "#"), clientSearchTime[i].String(), serverTimeList[i].String(), strconv.Itoa(resultLengthList[i])}
+		t := timesList[i]
+		resultData[i] = []string{strings.Join(keywords, "#"), t.ClientTime().String(), t.ServerTime().String(),
+			t.MitraTrapdoor.String(), t.MitraServer.String(), t.MitraDecrypt.String(),
+			t.AuhmeGenKey.String(), t.AuhmeServer.String(), t.Filter.String(),
+			strconv.Itoa(t.AuhmeKeys), strconv.Itoa(len(resultList[i])), t.volumes(keywords)}
 	}
 
 	// 将结果写入文件
@@ -470,29 +712,89 @@ func (hdxt *HDXT) SearchPhase(tableName, fileName string) {
 	}
 }
 
-func (hdxt *HDXT) SearchOneKeyword(keyword string) (time.Duration, time.Duration, []string, error) {
+// Search 连接查询：先用 Mitra 查询频率最低的关键字 w1，再用 AUHME 过滤其余关键字。
+// MitraOnly 模式下退化为 NaiveSearch。
+func (hdxt *HDXT) Search(keywords []string) ([]string, SearchTimes, error) {
+	hdxt.Recorder.Begin(hdxt.Name(), "search")
+	defer hdxt.endRecord()
+
+	if hdxt.Mode == MitraOnly {
+		return hdxt.NaiveSearch(keywords)
+	}
+
+	// 选择查询频率最低的关键字
+	if err := hdxt.loadFileCnt(keywords...); err != nil {
+		return nil, SearchTimes{}, err
+	}
+	counter, w1 := math.MaxInt64, keywords[0]
+	for _, w := range keywords {
+		num := hdxt.FileCnt[w]
+		if num < counter {
+			w1 = w
+			counter = num
+		}
+	}
+
+	// 单关键词搜索
+	w1Ids, times, err := hdxt.SearchOneKeyword(w1)
+	if err != nil {
+		return nil, times, err
+	}
+
+	// auhme part
+	// client search step 1
+	q := utils.RemoveElement(keywords, w1)
+	start := time.Now()
+	dkList, err := auhmeClientSearchStep1(hdxt, w1Ids, q)
+	if err != nil {
+		return nil, times, err
+	}
+	times.AuhmeGenKey = time.Since(start)
+	times.AuhmeKeys = len(dkList)
+
+	// server search step
+	start = time.Now()
+	posList := auhmeServerSearch(hdxt, dkList)
+	times.AuhmeServer = time.Since(start)
+
+	// client search step 2
+	start = time.Now()
+	sIdList := auhmeClientSearchStep2(w1Ids, posList)
+	times.Filter = time.Since(start)
+
+	return sIdList, times, nil
+}
+
+// SearchOneKeyword 单关键字 Mitra 查询，只填充 SearchTimes 中 Mitra 相关的耗时
+func (hdxt *HDXT) SearchOneKeyword(keyword string) ([]string, SearchTimes, error) {
+	hdxt.Recorder.Begin(hdxt.Name(), "search")
+	defer hdxt.endRecord()
+
+	var times SearchTimes
+
 	// 生成陷门
 	start := time.Now()
 	tList, err := mitraGenTrapdoor(hdxt, keyword)
 	if err != nil {
 		log.Println(err)
-		return 0, 0, nil, nil
+		return nil, times, err
 	}
-	clientTime := time.Since(start)
+	times.MitraTrapdoor = time.Since(start)
 
 	// server search
 	start = time.Now()
 	encryptedIds := mitraServerSearch(hdxt, tList)
-	serverTime := time.Since(start)
+	times.MitraServer = time.Since(start)
 
 	// client decrypt and return result
 	start = time.Now()
 	ids, err := mitraDecrypt(hdxt, keyword, encryptedIds)
 	if err != nil {
 		log.Println(err)
-		return 0, 0, nil, nil
+		return nil, times, err
 	}
-	clientTime += time.Since(start)
+	times.MitraDecrypt = time.Since(start)
+	times.KeywordVolumes = map[string]int{keyword: len(encryptedIds)}
 
-	return clientTime, serverTime, ids, nil
+	return ids, times, nil
 }
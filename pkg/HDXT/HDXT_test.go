@@ -0,0 +1,229 @@
+package HDXT
+
+import (
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/oram"
+	"ConjunctiveSSE/pkg/utils"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testDocs = []struct {
+	id       string
+	keywords []string
+}{
+	{"id1", []string{"a", "b"}},
+	{"id2", []string{"a", "c"}},
+	{"id3", []string{"a", "b", "c"}},
+}
+
+// newTestHDXT 创建不依赖 MongoDB 的 HDXT 实例并写入 testDocs
+func newTestHDXT(t *testing.T, mode SetupMode) *HDXT {
+	hdxt := &HDXT{Mode: mode, PadStep: 4}
+	if err := hdxt.InitClient(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range testDocs {
+		if _, _, err := hdxt.Setup(doc.id, doc.keywords, int(utils.Add)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hdxt
+}
+
+func search(t *testing.T, hdxt *HDXT, q ...string) []string {
+	ids, _, err := hdxt.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSearchAndDelete(t *testing.T) {
+	for _, mode := range []SetupMode{Dense, Sparse, MitraOnly} {
+		hdxt := newTestHDXT(t, mode)
+
+		if got, want := search(t, hdxt, "a", "b"), []string{"id1", "id3"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(a, b) = %v, want %v", mode, got, want)
+		}
+
+		_, tokList, err := hdxt.Delete("id1", []string{"a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tok := range tokList {
+			hdxt.AuhmeServer.ApplyUpd(tok)
+		}
+
+		if got, want := search(t, hdxt, "a", "b"), []string{"id3"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(a, b) after delete = %v, want %v", mode, got, want)
+		}
+		if got, want := search(t, hdxt, "b"), []string{"id3"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(b) after delete = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestGrowUniverse(t *testing.T) {
+	for _, mode := range []SetupMode{Dense, Sparse} {
+		hdxt := newTestHDXT(t, mode)
+
+		_, stats, tokList, err := hdxt.Encrypt("id4", []string{"b", "d"}, utils.Add)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tok := range tokList {
+			hdxt.AuhmeServer.ApplyUpd(tok)
+		}
+
+		wantGrowth := 0
+		if mode == Dense {
+			wantGrowth = len(testDocs)
+		}
+		if stats.growthEntries != wantGrowth {
+			t.Errorf("%v: growthEntries = %d, want %d", mode, stats.growthEntries, wantGrowth)
+		}
+		if got, want := search(t, hdxt, "d", "b"), []string{"id4"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(d, b) = %v, want %v", mode, got, want)
+		}
+		if got := search(t, hdxt, "d", "a"); len(got) != 0 {
+			t.Errorf("%v: Search(d, a) = %v, want []", mode, got)
+		}
+	}
+}
+
+func TestNaiveSearchVolumes(t *testing.T) {
+	hdxt := newTestHDXT(t, MitraOnly)
+
+	_, times, err := hdxt.Search([]string{"b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := times.volumes([]string{"b", "c"}), "b:2#c:2"; got != want {
+		t.Errorf("volumes = %q, want %q", got, want)
+	}
+	if times.AuhmeKeys != 0 || len(hdxt.AuhmeServer.EDB) != 0 {
+		t.Errorf("MitraOnly used AUHME: %d keys, %d entries", times.AuhmeKeys, len(hdxt.AuhmeServer.EDB))
+	}
+}
+
+func TestORAMFileCnt(t *testing.T) {
+	hdxt := &HDXT{Mode: Sparse, PadStep: 4}
+	if err := hdxt.InitClient(true); err != nil {
+		t.Fatal(err)
+	}
+	m, err := oram.NewMap(oram.NewMemoryBucketStore(), []byte("0123456789abcdef"), 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdxt.FileCntStore = m
+	for _, doc := range testDocs {
+		if _, _, err := hdxt.Setup(doc.id, doc.keywords, int(utils.Add)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 丢弃本地计数后仍能从 ORAM 中恢复
+	hdxt.FileCnt = make(map[string]int)
+	if got, want := search(t, hdxt, "b", "c"), []string{"id3"}; !slices.Equal(got, want) {
+		t.Errorf("Search(b, c) = %v, want %v", got, want)
+	}
+	if cnt, err := m.GetInt("a"); err != nil || cnt != 3 {
+		t.Errorf("FileCnt[a] in ORAM = %d, %v, want 3", cnt, err)
+	}
+}
+
+func TestLeakageRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "leakage.jsonl")
+	recorder, err := leakage.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdxt := &HDXT{Mode: Sparse, PadStep: 4, Recorder: recorder}
+	if err := hdxt.InitClient(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range testDocs {
+		if _, _, err := hdxt.Setup(doc.id, doc.keywords, int(utils.Add)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search(t, hdxt, "b", "c")
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := leakage.ReadEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != len(testDocs)+1 {
+		t.Fatalf("got %d events, want %d", len(events), len(testDocs)+1)
+	}
+	for i, doc := range testDocs {
+		e := events[i]
+		if e.Type != "update" || len(e.Addresses) != len(doc.keywords) || len(e.AuhmeLabels) != 4 {
+			t.Errorf("update %d: type %s, %d addresses, %d labels", i, e.Type, len(e.Addresses), len(e.AuhmeLabels))
+		}
+	}
+	// b 和 c 的计数相同时选择 b，Mitra 访问 2 个地址，AUHME 对 2 个候选查询并匹配 id3
+	e := events[len(testDocs)]
+	if e.Type != "search" || len(e.Addresses) != 2 || len(e.AuhmeQueries) != 2 || !slices.Equal(e.Positions, []int{1}) {
+		t.Errorf("search event = %+v", e)
+	}
+}
+
+func TestMitraPrfDomains(t *testing.T) {
+	k := []byte("0123456789abcdef")
+	for _, tt := range []struct {
+		name   string
+		label1 byte
+		w1     string
+		cnt1   int
+		label2 byte
+		w2     string
+		cnt2   int
+	}{
+		// 旧编码下 w||257||0 与 w||1||1 同为 w||01 01
+		{"address vs value", labelAddress, "w", 257, labelValue, "w", 1},
+		// 旧编码下 "a"||0x62 与 "ab"||0 同为 "ab"
+		{"keyword boundary", labelAddress, "a", 0x62, labelAddress, "ab", 0},
+	} {
+		a, err := mitraPrf(k, tt.label1, tt.w1, tt.cnt1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := mitraPrf(k, tt.label2, tt.w2, tt.cnt2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if slices.Equal(a, b) {
+			t.Errorf("%s: PRF inputs collide", tt.name)
+		}
+	}
+}
+
+// TestSetupThenUpdate 与 SetupPhase 相同：先 Setup 全部文档，再以 Add 重新加密同一批文档
+func TestSetupThenUpdate(t *testing.T) {
+	for _, mode := range []SetupMode{Dense, Sparse, MitraOnly} {
+		hdxt := newTestHDXT(t, mode)
+		for _, doc := range testDocs {
+			_, _, tokList, err := hdxt.Encrypt(doc.id, doc.keywords, utils.Add)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tok := range tokList {
+				hdxt.AuhmeServer.ApplyUpd(tok)
+			}
+		}
+
+		if got, want := search(t, hdxt, "a", "b"), []string{"id1", "id3"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(a, b) after update = %v, want %v", mode, got, want)
+		}
+		if got, want := search(t, hdxt, "c", "a"), []string{"id2", "id3"}; !slices.Equal(got, want) {
+			t.Errorf("%v: Search(c, a) after update = %v, want %v", mode, got, want)
+		}
+	}
+}
@@ -1,321 +1,71 @@
 package HDXT
 
 import (
+	"ConjunctiveSSE/pkg/auhme"
+	"ConjunctiveSSE/pkg/leakage"
 	"ConjunctiveSSE/pkg/utils"
-	"crypto/rand"
-	"crypto/sha256"
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"log"
-	"math/big"
-	"strings"
 )
 
+// mitraEncrypt 生成一条 Mitra 密文，operation 为 utils.Add 或 utils.Del
 func mitraEncrypt(hdxt *HDXT, keyword string, id string, operation int) (string, string, error) {
-	hdxt.FileCnt[keyword]++
-	k := hdxt.Mitra.Key
-
-	wWc := append([]byte(keyword), big.NewInt(int64(hdxt.FileCnt[keyword])).Bytes()...)
-
-	// address = PRF(kt, w||wc||0)
-	address, err := utils.PrfF(k, append(wWc, big.NewInt(int64(0)).Bytes()...))
-	if err != nil {
-		return "", "", err
-	}
-
-	// val = PRF(kt, w||wc||1) xor (id||op)
-	val, err := utils.PrfF(k, append(wWc, big.NewInt(int64(1)).Bytes()...))
-	if err != nil {
+	if err := hdxt.loadFileCnt(keyword); err != nil {
 		return "", "", err
 	}
-	val, err = utils.BytesXORWithOp(val, []byte(id), operation)
-	if err != nil {
+	hdxt.FileCnt[keyword]++
+	if err := hdxt.storeFileCnt(keyword); err != nil {
 		return "", "", err
 	}
+	k, cnt := hdxt.Mitra.Key, hdxt.FileCnt[keyword]
 
-	return base64.StdEncoding.EncodeToString(address), base64.StdEncoding.EncodeToString(val), nil
-}
-
-func auhmeEncrypt(hdxt *HDXT, keyword string, id string, flag int, cnt int) (string, string, error) {
-	// label = PRF(k1, w||id)
-	k1, k2, k3 := hdxt.Auhme.Keys[0], hdxt.Auhme.Keys[1], hdxt.Auhme.Keys[2]
-	wId := append([]byte(keyword), []byte(id)...)
-	label, err := utils.FAesni(k1, wId, 1)
+	// address = PRF(kt, 0||w||wc)
+	address, err := mitraPrf(k, labelAddress, keyword, cnt)
 	if err != nil {
 		return "", "", err
 	}
 
-	v := append([]byte(label), byte(flag))
-	enc1, err := utils.FAesni(k2, v, 1)
+	// val = PRF(kt, 1||w||wc) xor (id||op)
+	val, err := mitraPrf(k, labelValue, keyword, cnt)
 	if err != nil {
 		return "", "", err
 	}
-
-	v = append([]byte(label), byte(cnt))
-	enc2, err := utils.FAesni(k3, v, 1)
+	val, err = utils.BytesXORWithOp(val, []byte(id), operation)
 	if err != nil {
 		return "", "", err
 	}
 
-	// xor enc1 and enc2
-	enc := utils.Xor(enc1, enc2)
-	return base64.StdEncoding.EncodeToString(label), base64.StdEncoding.EncodeToString(enc), nil
+	return base64.StdEncoding.EncodeToString(address), base64.StdEncoding.EncodeToString(val), nil
 }
 
-type Operation int
-
+// Mitra PRF 输入的用途标签
 const (
-	Add Operation = iota
-	Edit
-	EditMinus
-	EditPlus
+	labelAddress byte = iota // 密文地址
+	labelValue               // (id, op) 的掩码
 )
 
-type Delta struct {
-	cnt   int
-	t     map[string]int
-	delta int
-	s     []string
-}
-
-type UTok struct {
-	tok map[string]string
-	op  Operation
-}
-
-func auhmeGenUpd(hdxt *HDXT, op Operation, ku string, vu int) (*UTok, *Delta, error) {
-	k1, k2, k3 := hdxt.Auhme.Keys[0], hdxt.Auhme.Keys[1], hdxt.Auhme.Keys[2]
-	cnt, s, t, delta := hdxt.Auhme.Deltas.cnt, hdxt.Auhme.Deltas.s, hdxt.Auhme.Deltas.t, hdxt.Auhme.Deltas.delta
-	tok := make(map[string]string)
-	if op == Add {
-		// l ← F (k1, ku )
-		l, err := utils.FAesni(k1, []byte(ku), 1)
-		if err != nil {
-			return nil, nil, err
-		}
-		tok1, err := utils.FAesni(k2, append([]byte(l), byte(vu)), 1)
-		if err != nil {
-			return nil, nil, err
-		}
-		tok2, err := utils.FAesni(k3, append([]byte(l), byte(cnt)), 1)
-		if err != nil {
-			return nil, nil, err
-		}
-		tok[base64.StdEncoding.EncodeToString(l)] = base64.StdEncoding.EncodeToString(utils.Xor(tok1, tok2))
-		return &UTok{tok, op}, &Delta{cnt, t, delta, s}, nil
-	}
-
-	var err error
-	t, err = CInsert(k1, ku, vu, t)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	if len(t)+1 < delta {
-		return nil, &Delta{cnt, t, delta, nil}, nil
-	} else {
-		s = make([]string, 0)
-		for k := range hdxt.AuhmeCipherList {
-			s = append(s, k)
-		}
-		tok, err = CEvict(hdxt, s)
-		if err != nil {
-			return nil, nil, err
-		}
-		CClear(hdxt)
-		return &UTok{tok, Edit}, &Delta{cnt + 1, t, delta, nil}, nil
-	}
-}
-
-// CInsert inserts a key-value pair into the map. If the key already exists, it deletes the existing key-value pair.
-func CInsert(k1 []byte, k string, v int, t map[string]int) (map[string]int, error) {
-	l, err := utils.FAesni(k1, []byte(k), 1)
-	if err != nil {
-		return nil, err
-	}
-	delete(t, base64.StdEncoding.EncodeToString(l))
-	t[base64.StdEncoding.EncodeToString(l)] = v
-	return t, nil
-}
-
-func CEvict(hdxt *HDXT, s []string) (tok map[string]string, err error) {
-	k2, k3 := hdxt.Auhme.Keys[1], hdxt.Auhme.Keys[2]
-	cnt, t := hdxt.Auhme.Deltas.cnt, hdxt.Auhme.Deltas.t
-	tok = make(map[string]string)
-	for _, l := range s {
-		if _, ok := t[l]; !ok {
-			u1, err := utils.FAesni(k3, append([]byte(l), byte(cnt)), 1)
-			if err != nil {
-				return nil, err
-			}
-			u2, err := utils.FAesni(k3, append([]byte(l), byte(cnt+1)), 1)
-			if err != nil {
-				return nil, err
-			}
-			tok[l] = base64.StdEncoding.EncodeToString(utils.Xor(u1, u2))
-		}
-		b := t[l]
-		u1, err := utils.FAesni(k2, append([]byte(l), byte(b)), 1)
-		if err != nil {
-			return nil, err
-		}
-		u2, err := utils.FAesni(k2, append([]byte(l), byte(1-b)), 1)
-		if err != nil {
-			return nil, err
-		}
-		u3, err := utils.FAesni(k3, append([]byte(l), byte(cnt)), 1)
-		if err != nil {
-			return nil, err
-		}
-		u4, err := utils.FAesni(k3, append([]byte(l), byte(cnt+1)), 1)
-		if err != nil {
-			return nil, err
-		}
-		tok[l] = base64.StdEncoding.EncodeToString(utils.Xor(utils.Xor(u1, u2), utils.Xor(u3, u4)))
-	}
-	return tok, nil
-}
-
-func CClear(hdxt *HDXT) {
-	// delete all keys in t
-	hdxt.Auhme.Deltas.t = make(map[string]int)
-}
-
-func auhmeApplyUpd(hdxt *HDXT, utok *UTok) {
-	tok, op := utok.tok, utok.op
-	for l, v := range tok {
-		if op == Add {
-			hdxt.AuhmeCipherList[l] = v
-		} else {
-			dec := xor(hdxt.AuhmeCipherList[l], v)
-			hdxt.AuhmeCipherList[l] = dec
-		}
-	}
-}
-
-func xor(s1, s2 string) string {
-	// 将字符串转换为字节切片
-	b1 := []byte(s1)
-	b2 := []byte(s2)
-
-	// 获取较短的长度
-	minLen := len(b1)
-	if len(b2) < minLen {
-		minLen = len(b2)
-	}
-
-	// 使用较长的切片作为结果
-	var result []byte
-	if len(b1) > len(b2) {
-		result = make([]byte, len(b1))
-		copy(result, b1)
-	} else {
-		result = make([]byte, len(b2))
-		copy(result, b2)
-	}
-
-	// 对最小长度的部分进行异或操作
-	for i := 0; i < minLen; i++ {
-		result[i] = b1[i] ^ b2[i]
-	}
-
-	return string(result)
+// mitraPrf 计算 PRF(k, label||len(w)||w||cnt)。关键字带 8 字节长度前缀、计数器为 8 字节大端编码，
+// 不同的 (label, w, cnt) 不会得到相同的输入，服务器无法由地址推出掩码
+func mitraPrf(k []byte, label byte, keyword string, cnt int) ([]byte, error) {
+	in := make([]byte, 0, 1+8+len(keyword)+8)
+	in = append(in, label)
+	in = binary.BigEndian.AppendUint64(in, uint64(len(keyword)))
+	in = append(in, keyword...)
+	in = binary.BigEndian.AppendUint64(in, uint64(cnt))
+	return utils.PrfF(k, in)
 }
 
-func auhmeGenKey(hdxt *HDXT, mp map[string]int) (*dk, error) {
-	k1, k2, k3 := hdxt.Auhme.Keys[0], hdxt.Auhme.Keys[1], hdxt.Auhme.Keys[2]
-	cnt := hdxt.Auhme.Deltas.cnt
-	L := make([]string, 0)
-	beta := 1
-	xors := strings.Repeat("0", 16)
-	for k, v := range mp {
-		l, err := utils.FAesni(k1, []byte(k), 1)
-		if err != nil {
-			return nil, err
-		}
-		L = append(L, base64.StdEncoding.EncodeToString(l))
-		cv, err := CFind(hdxt, k)
-		if err != nil {
-			return nil, err
-		}
-		if cv == 1-v {
-			beta = 0
-		} else if cv == v {
-			v1, err := utils.FAesni(k2, append([]byte(l), byte(1-v)), 1)
-			if err != nil {
-				return nil, err
-			}
-			v2, err := utils.FAesni(k3, append([]byte(l), byte(cnt)), 1)
-			if err != nil {
-				return nil, err
-			}
-			xors = xor(xors, base64.StdEncoding.EncodeToString(utils.Xor(v1, v2)))
-		} else if cv == -1 {
-			v1, err := utils.FAesni(k2, append(l, byte(v)), 1)
-			if err != nil {
-				return nil, err
-			}
-			v2, err := utils.FAesni(k3, append(l, byte(cnt)), 1)
-			if err != nil {
-				return nil, err
-			}
-			xors = xor(xors, base64.StdEncoding.EncodeToString(utils.Xor(v1, v2)))
-		}
-	}
-	randomBytes := make([]byte, 16)
-	if _, err := rand.Read(randomBytes); err != nil {
-		return nil, err
-	}
-	r := base64.StdEncoding.EncodeToString(randomBytes)
-	if beta == 1 {
-		h := sha256.New()
-		h.Write([]byte(r + xors))
-		d := base64.StdEncoding.EncodeToString(h.Sum(nil))
-		return &dk{L, r, d}, nil
-	}
-	if _, err := rand.Read(randomBytes); err != nil {
+func mitraGenTrapdoor(hdxt *HDXT, keyword string) ([]string, error) {
+	if err := hdxt.loadFileCnt(keyword); err != nil {
 		return nil, err
 	}
-	d := base64.StdEncoding.EncodeToString(randomBytes)
-	return &dk{L, r, d}, nil
-}
-
-type dk struct {
-	L []string
-	r string
-	d string
-}
-
-func CFind(hdxt *HDXT, k string) (int, error) {
-	k1 := hdxt.Auhme.Keys[0]
-	l, err := utils.FAesni(k1, []byte(k), 1)
-	if err != nil {
-		return -1, err
-	}
-	if v, ok := hdxt.Auhme.Deltas.t[base64.StdEncoding.EncodeToString(l)]; ok {
-		return v, nil
-	}
-	return -1, nil
-}
-
-func auhmeQuery(hdxt *HDXT, dk *dk) int {
-	xors := strings.Repeat("0", 16)
-	for _, l := range dk.L {
-		xors = xor(xors, hdxt.AuhmeCipherList[l])
-	}
-	h := sha256.New()
-	h.Write([]byte(dk.r + xors))
-	d := base64.StdEncoding.EncodeToString(h.Sum(nil))
-	if d == dk.d {
-		return 1
-	}
-	return 0
-}
-
-func mitraGenTrapdoor(hdxt *HDXT, keyword string) ([]string, error) {
 	tList := make([]string, 0, hdxt.FileCnt[keyword])
 	for i := 1; i <= hdxt.FileCnt[keyword]; i++ {
-		//Ti = PrfF(kt, w||i||0)
-		address, err := utils.PrfF(hdxt.Mitra.Key, append(append([]byte(keyword), big.NewInt(int64(i)).Bytes()...), byte(0)))
+		// Ti = PRF(kt, 0||w||i)，与 mitraEncrypt 中的 address 编码一致
+		address, err := mitraPrf(hdxt.Mitra.Key, labelAddress, keyword, i)
 		if err != nil {
 			return nil, err
 		}
@@ -324,20 +74,33 @@ func mitraGenTrapdoor(hdxt *HDXT, keyword string) ([]string, error) {
 	return tList, nil
 }
 
+// mitraServerSearch 按陷门顺序返回密文，不存在的地址对应空字符串
 func mitraServerSearch(hdxt *HDXT, tList []string) []string {
-	result := make([]string, 0, len(tList))
-	for _, t := range tList {
-		if _, ok := hdxt.MitraCipherList[t]; ok {
-			result = append(result, hdxt.MitraCipherList[t])
+	result := make([]string, len(tList))
+	for i, t := range tList {
+		result[i] = hdxt.MitraCipherList[t]
+	}
+	hdxt.Recorder.Record(func(e *leakage.Event) {
+		e.Addresses = append(e.Addresses, tList...)
+		for i, r := range result {
+			if r != "" {
+				e.Found = append(e.Found, i)
+			}
 		}
-	}
+	})
 	return result
 }
 
+// mitraDecrypt 按更新顺序解密 (id, op)，在客户端对添加和删除进行合并，
+// 只返回最后一次操作为添加的 id
 func mitraDecrypt(hdxt *HDXT, keyword string, encs []string) ([]string, error) {
-	dec := make([]string, 0, len(encs))
+	order := make([]string, 0, len(encs))
+	alive := make(map[string]bool, len(encs))
 	for i, e := range encs {
-		laber, err := utils.PrfF(hdxt.Mitra.Key, append(append([]byte(keyword), big.NewInt(int64(i)).Bytes()...), byte(1)))
+		if e == "" {
+			continue
+		}
+		label, err := mitraPrf(hdxt.Mitra.Key, labelValue, keyword, i+1)
 		if err != nil {
 			return nil, err
 		}
@@ -345,20 +108,36 @@ func mitraDecrypt(hdxt *HDXT, keyword string, encs []string) ([]string, error) {
 		if err != nil {
 			return nil, err
 		}
-		idOp := utils.BytesXOR(eBytes, laber)
-		dec = append(dec, string(idOp[:len(idOp)-1]))
+		idOp := utils.BytesXOR(eBytes, label)
+		id := string(bytes.TrimRight(idOp[:len(idOp)-1], "\x00"))
+		switch utils.Operation(idOp[len(idOp)-1]) {
+		case utils.Add:
+			if _, ok := alive[id]; !ok {
+				order = append(order, id)
+			}
+			alive[id] = true
+		case utils.Del:
+			alive[id] = false
+		}
+	}
+
+	dec := make([]string, 0, len(order))
+	for _, id := range order {
+		if alive[id] {
+			dec = append(dec, id)
+		}
 	}
 	return dec, nil
 }
 
-func auhmeClientSearchStep1(hdxt *HDXT, w1Ids []string, q []string) ([]*dk, error) {
-	DK := make([]*dk, 0, len(w1Ids))
+func auhmeClientSearchStep1(hdxt *HDXT, w1Ids []string, q []string) ([]*auhme.DecryptionKey, error) {
+	DK := make([]*auhme.DecryptionKey, 0, len(w1Ids))
 	for _, id := range w1Ids {
 		I := make(map[string]int, len(q))
 		for _, w := range q {
 			I[w+id] = 1
 		}
-		dk, err := auhmeGenKey(hdxt, I)
+		dk, err := hdxt.Auhme.GenKey(I)
 		if err != nil {
 			log.Println(err)
 			return nil, err
@@ -368,13 +147,22 @@ func auhmeClientSearchStep1(hdxt *HDXT, w1Ids []string, q []string) ([]*dk, erro
 	return DK, nil
 }
 
-func auhmeServerSearch(hdxt *HDXT, DK []*dk) []int {
+func auhmeServerSearch(hdxt *HDXT, DK []*auhme.DecryptionKey) []int {
 	result := make([]int, 0, len(DK))
+	queries := make([]leakage.AuhmeQuery, 0, len(DK))
 	for i, dk := range DK {
-		if auhmeQuery(hdxt, dk) == 1 {
+		match := hdxt.AuhmeServer.Query(dk)
+		if match {
 			result = append(result, i)
 		}
+		if hdxt.Recorder != nil {
+			queries = append(queries, leakage.AuhmeQuery{Labels: blocksToHex(dk.L), Match: match})
+		}
 	}
+	hdxt.Recorder.Record(func(e *leakage.Event) {
+		e.AuhmeQueries = append(e.AuhmeQueries, queries...)
+		e.Positions = append(e.Positions, result...)
+	})
 	return result
 }
 
@@ -385,3 +173,41 @@ func auhmeClientSearchStep2(w1Ids []string, posList []int) []string {
 	}
 	return result
 }
+
+// recordAddress 记录更新写入的 Mitra 地址
+func (hdxt *HDXT) recordAddress(address string) {
+	hdxt.Recorder.Record(func(e *leakage.Event) {
+		e.Addresses = append(e.Addresses, address)
+	})
+}
+
+// recordTokens 记录更新令牌中服务器将写入的 AUHME 标签
+func (hdxt *HDXT) recordTokens(tokList ...*auhme.UpdateToken) {
+	if hdxt.Recorder == nil {
+		return
+	}
+	labels := make([]auhme.Block, 0)
+	for _, tok := range tokList {
+		for label := range tok.Tok {
+			labels = append(labels, label)
+		}
+	}
+	hdxt.Recorder.Record(func(e *leakage.Event) {
+		e.AuhmeLabels = append(e.AuhmeLabels, blocksToHex(labels)...)
+	})
+}
+
+// endRecord 结束当前泄露事件
+func (hdxt *HDXT) endRecord() {
+	if err := hdxt.Recorder.End(); err != nil {
+		log.Println(err)
+	}
+}
+
+func blocksToHex(blocks []auhme.Block) []string {
+	result := make([]string, len(blocks))
+	for i, b := range blocks {
+		result[i] = hex.EncodeToString(b[:])
+	}
+	return result
+}
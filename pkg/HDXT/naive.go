@@ -0,0 +1,49 @@
+package HDXT
+
+import (
+	"time"
+)
+
+// NaiveSearch 朴素连接查询：对每个关键字执行 Mitra 单关键字查询，在客户端求交。
+// 服务器可以看到每个关键字的结果数量，记录在 KeywordVolumes 中。
+func (hdxt *HDXT) NaiveSearch(keywords []string) ([]string, SearchTimes, error) {
+	times := SearchTimes{KeywordVolumes: make(map[string]int, len(keywords))}
+
+	var result []string
+	for i, keyword := range keywords {
+		ids, t, err := hdxt.SearchOneKeyword(keyword)
+		if err != nil {
+			return nil, times, err
+		}
+		times.MitraTrapdoor += t.MitraTrapdoor
+		times.MitraServer += t.MitraServer
+		times.MitraDecrypt += t.MitraDecrypt
+		times.KeywordVolumes[keyword] = t.KeywordVolumes[keyword]
+
+		// client 求交
+		start := time.Now()
+		if i == 0 {
+			result = ids
+		} else {
+			result = intersect(result, ids)
+		}
+		times.Filter += time.Since(start)
+	}
+
+	return result, times, nil
+}
+
+// intersect 返回同时出现在 a 和 b 中的元素，保持 a 中的顺序
+func intersect(a, b []string) []string {
+	set := make(map[string]struct{}, len(b))
+	for _, v := range b {
+		set[v] = struct{}{}
+	}
+	result := make([]string, 0, len(a))
+	for _, v := range a {
+		if _, ok := set[v]; ok {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,65 @@
+package HDXT
+
+import (
+	"ConjunctiveSSE/pkg/auhme"
+	"log"
+	"time"
+)
+
+// indexUniverse 根据 UniverseKeywords 和 UniverseIDs 重建查找用的集合
+func (hdxt *HDXT) indexUniverse() {
+	hdxt.keywordSet = make(map[string]struct{}, len(hdxt.UniverseKeywords))
+	for _, w := range hdxt.UniverseKeywords {
+		hdxt.keywordSet[w] = struct{}{}
+	}
+	hdxt.idSet = make(map[string]struct{}, len(hdxt.UniverseIDs))
+	for _, id := range hdxt.UniverseIDs {
+		hdxt.idSet[id] = struct{}{}
+	}
+}
+
+// GrowUniverse 将文档 id 及其关键字中未出现过的关键字加入全集。
+// 对每个新关键字 w，为已有的每个文档 id' 生成 (w, id') -> 0 的 AUHME 条目；
+// 新文档 id 与全部关键字的条目由 Setup/Encrypt 自身生成。
+// Sparse 和 MitraOnly 模式下缺失的条目视为 0，不需要为新关键字生成任何条目。
+// 返回需要下发到服务器的更新令牌（无新关键字时为 nil）及其耗时。
+func (hdxt *HDXT) GrowUniverse(id string, keywords []string) (*auhme.UpdateToken, time.Duration, error) {
+	if hdxt.keywordSet == nil || hdxt.idSet == nil {
+		hdxt.indexUniverse()
+	}
+
+	start := time.Now()
+	var utok *auhme.UpdateToken
+	for _, keyword := range keywords {
+		if _, ok := hdxt.keywordSet[keyword]; ok {
+			continue
+		}
+		if hdxt.Mode != Dense {
+			hdxt.keywordSet[keyword] = struct{}{}
+			hdxt.UniverseKeywords = append(hdxt.UniverseKeywords, keyword)
+			continue
+		}
+		if utok == nil {
+			utok = &auhme.UpdateToken{Tok: make(map[auhme.Block]auhme.Block), Op: auhme.Add}
+		}
+		for _, oldID := range hdxt.UniverseIDs {
+			tok, err := hdxt.Auhme.GenUpd(auhme.Add, keyword+oldID, 0)
+			if err != nil {
+				log.Println("Error in GrowUniverse:", err)
+				return nil, 0, err
+			}
+			for l, v := range tok.Tok {
+				utok.Tok[l] = v
+			}
+		}
+		hdxt.keywordSet[keyword] = struct{}{}
+		hdxt.UniverseKeywords = append(hdxt.UniverseKeywords, keyword)
+	}
+
+	if _, ok := hdxt.idSet[id]; !ok {
+		hdxt.idSet[id] = struct{}{}
+		hdxt.UniverseIDs = append(hdxt.UniverseIDs, id)
+	}
+
+	return utok, time.Since(start), nil
+}
@@ -0,0 +1,206 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"log"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// HXT 隐藏交叉标签模式（参考 Lai 等人的 HXT）
+//
+// XSet 的每一位 b_i 以对称隐藏向量加密（SHVE）的形式保存在服务器端：c_i = E(msk, i||b_i)。
+// 第一轮沿用 ODXT.Search，服务器计算 xtag 后只记录其在 Bloom 过滤器中的位置而不做成员测试；
+// 第二轮客户端为每个 s-term 条目生成覆盖其全部 x-term 位置的 SHVE 令牌，
+// 服务器仅能判断该条目是否满足整个连接查询，不再得知单个关键字对的匹配结果（KPRP）。
+// 该模式下明文 XSet 只由客户端保存，用于重新生成 EXSet。
+type HXT struct {
+	msk cipher.Block
+	m   uint
+	k   uint
+	// EXSet 为服务器端 SHVE 密文，每位占 aes.BlockSize 字节
+	EXSet []byte
+	// locations 为服务器第一轮得到的各条目的 Bloom 位置，在第二轮前发送给客户端
+	locations [][]uint64
+}
+
+// HXTStats 单次 HXT 查询第二轮的开销和泄露
+type HXTStats struct {
+	KeyGenTime   time.Duration // 客户端生成 SHVE 令牌的时间
+	Round2Time   time.Duration // 服务器计算 SHVE 查询的时间
+	TokenBytes   int           // 第二轮上传的令牌大小
+	PairBits     int           // 同一查询在 ODXT 中服务器可见的关键字对匹配结果数
+	RevealedBits int           // HXT 中服务器可见的匹配结果数，每个 s-term 条目一位
+}
+
+// EnableHXT 生成 SHVE 主密钥并加密当前 XSet 的全部位，之后 Search 不再测试明文 XSet
+func (odxt *ODXT) EnableHXT() error {
+	if odxt.Verifier != nil {
+		return ErrVerifyHXT
+	}
+	h := &HXT{m: odxt.XSet.Cap(), k: odxt.XSet.K()}
+	h.EXSet = make([]byte, h.m*aes.BlockSize)
+	if err := h.rekey(odxt.XSet); err != nil {
+		return err
+	}
+	odxt.HXT = h
+	return nil
+}
+
+// rekey 以新的主密钥重新加密 xSet 的全部位，由 EnableHXT 和 Encrypt 调用。
+// 密文 E(msk, i||b_i) 是确定性的，若只重新加密新置位的位置，服务器比较更新前后的 EXSet
+// 即可得知哪些位从 0 变为 1、哪些 xtag 的位置已经置位，因此每次更新都整体重写 EXSet
+func (h *HXT) rekey(xSet *bloom.BloomFilter) error {
+	msk := make([]byte, 16)
+	if _, err := rand.Read(msk); err != nil {
+		return err
+	}
+	block, err := aes.NewCipher(msk)
+	if err != nil {
+		return err
+	}
+	h.msk = block
+	bits := xSet.BitSet()
+	for i := uint(0); i < h.m; i++ {
+		h.encryptBit(h.EXSet[i*aes.BlockSize:(i+1)*aes.BlockSize], uint64(i), bits.Test(i))
+	}
+	return nil
+}
+
+// encryptBit dst = E(msk, i||b)
+func (h *HXT) encryptBit(dst []byte, i uint64, b bool) {
+	var in [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(in[:8], i)
+	if b {
+		in[8] = 1
+	}
+	h.msk.Encrypt(dst, in[:])
+}
+
+// positions 计算 xtag 在 Bloom 过滤器中的 k 个位置
+func (h *HXT) positions(xtag []byte) []uint64 {
+	locs := bloom.Locations(xtag, h.k)
+	for i := range locs {
+		locs[i] %= uint64(h.m)
+	}
+	return locs
+}
+
+// collect 服务器第一轮记录第 j 个条目的 xtag 位置
+func (h *HXT) collect(j int, xtag []byte) {
+	h.locations[j] = append(h.locations[j], h.positions(xtag)...)
+}
+
+// SHVEToken 匹配谓词"所选位置全部为 1"的 SHVE 令牌
+type SHVEToken struct {
+	Positions []uint64
+	D0        []byte
+	D1        []byte
+}
+
+// genToken 客户端为位置集合生成 SHVE 令牌：D0 = K xor ⊕E(msk, i||1)，D1 = E(K, 0)
+func (h *HXT) genToken(positions []uint64) (*SHVEToken, error) {
+	k := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(k); err != nil {
+		return nil, err
+	}
+	d0 := append([]byte(nil), k...)
+	c := make([]byte, aes.BlockSize)
+	for _, loc := range positions {
+		h.encryptBit(c, loc, true)
+		for i := range d0 {
+			d0[i] ^= c[i]
+		}
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	d1 := make([]byte, aes.BlockSize)
+	block.Encrypt(d1, make([]byte, aes.BlockSize))
+	return &SHVEToken{positions, d0, d1}, nil
+}
+
+// query 服务器用 EXSet 计算 SHVE 查询，位置全部为 1 时恢复出正确的 K
+func (h *HXT) query(tok *SHVEToken) (bool, error) {
+	k := append([]byte(nil), tok.D0...)
+	for _, loc := range tok.Positions {
+		c := h.EXSet[loc*aes.BlockSize : (loc+1)*aes.BlockSize]
+		for i := range k {
+			k[i] ^= c[i]
+		}
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return false, err
+	}
+	d1 := make([]byte, aes.BlockSize)
+	block.Encrypt(d1, make([]byte, aes.BlockSize))
+	return bytes.Equal(d1, tok.D1), nil
+}
+
+// HXTSearch 两轮的 HXT 查询，返回值与 Search 相同，第二轮的开销和泄露记录在 HXTStats 中
+func (odxt *ODXT) HXTSearch(q []string, tableName string) (time.Duration, time.Duration, []utils.SEOp, *HXTStats, error) {
+	h := odxt.HXT
+	odxt.Recorder.Begin("HXT", "search")
+	defer func() {
+		if err := odxt.Recorder.End(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	// 第一轮：服务器计算 xtag 并记录位置
+	trapdoorTime, serverTime, sEOpList, err := odxt.Search(q, tableName)
+	if err != nil {
+		return 0, 0, nil, nil, err
+	}
+	stats := &HXTStats{PairBits: len(sEOpList) * (len(q) - 1)}
+	if len(q) == 1 {
+		return trapdoorTime, serverTime, sEOpList, stats, nil
+	}
+
+	// 第二轮：客户端生成 SHVE 令牌
+	start := time.Now()
+	tokens := make([]*SHVEToken, len(sEOpList))
+	for j := range tokens {
+		tok, err := h.genToken(h.locations[j])
+		if err != nil {
+			return 0, 0, nil, nil, err
+		}
+		tokens[j] = tok
+		stats.TokenBytes += len(tok.Positions)*8 + len(tok.D0) + len(tok.D1)
+	}
+	stats.KeyGenTime = time.Since(start)
+
+	// 服务器对每个条目计算 SHVE 查询
+	start = time.Now()
+	for j, tok := range tokens {
+		ok, err := h.query(tok)
+		if err != nil {
+			return 0, 0, nil, nil, err
+		}
+		if ok {
+			sEOpList[j].Cnt = len(q)
+		}
+	}
+	stats.Round2Time = time.Since(start)
+	stats.RevealedBits = len(sEOpList)
+
+	// 第二轮服务器只得知满足整个查询的条目
+	odxt.Recorder.Record(func(e *leakage.Event) {
+		for j, sEOp := range sEOpList {
+			if sEOp.Cnt == len(q) {
+				e.Positions = append(e.Positions, j)
+			}
+		}
+	})
+
+	return trapdoorTime + stats.KeyGenTime, serverTime + stats.Round2Time, sEOpList, stats, nil
+}
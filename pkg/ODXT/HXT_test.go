@@ -0,0 +1,74 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"crypto/aes"
+	"slices"
+	"testing"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+func TestHXTSearch(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.XSet = bloom.NewWithEstimates(1000, 0.001)
+
+	// 启用前后的更新都应反映在 EXSet 中
+	odxt.update(utils.Add, "a", "id1", "id2", "id3")
+	if err := odxt.EnableHXT(); err != nil {
+		t.Fatal(err)
+	}
+	odxt.update(utils.Add, "b", "id1", "id3", "id4")
+	odxt.update(utils.Add, "c", "id3", "id4")
+
+	for _, q := range [][]string{{"a", "b"}, {"c", "a", "b"}, {"b", "a"}, {"a"}} {
+		_, _, sEOpList, stats, err := odxt.HXTSearch(q, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := odxt.Decrypt(q, sEOpList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+
+		hxt := odxt.HXT
+		odxt.HXT = nil
+		want := odxt.search(q...)
+		odxt.HXT = hxt
+
+		if !slices.Equal(got, want) {
+			t.Errorf("HXTSearch(%v) = %v, want %v", q, got, want)
+		}
+		if len(q) > 1 && stats.RevealedBits != len(sEOpList) {
+			t.Errorf("HXTSearch(%v) revealed %d bits, want %d", q, stats.RevealedBits, len(sEOpList))
+		}
+	}
+}
+
+func TestHXTUpdateRewritesEXSet(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.XSet = bloom.NewWithEstimates(100, 0.01)
+	odxt.update(utils.Add, "a", "id1", "id2")
+	odxt.update(utils.Add, "b", "id1")
+	if err := odxt.EnableHXT(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 再次写入已存在的 xtag 也不能让服务器看到未变化的密文
+	before := slices.Clone(odxt.HXT.EXSet)
+	odxt.update(utils.Add, "b", "id1")
+	for i := 0; i < len(before); i += aes.BlockSize {
+		if bytes.Equal(before[i:i+aes.BlockSize], odxt.HXT.EXSet[i:i+aes.BlockSize]) {
+			t.Fatalf("EXSet block %d unchanged after update", i/aes.BlockSize)
+		}
+	}
+	_, _, sEOpList, _, err := odxt.HXTSearch([]string{"b", "a"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := odxt.Decrypt([]string{"b", "a"}, sEOpList); err != nil || !slices.Equal(got, []string{"id1", "id1"}) {
+		t.Errorf("HXTSearch(b, a) = %v, %v, want [id1 id1]", got, err)
+	}
+}
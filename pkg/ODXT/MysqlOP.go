@@ -2,9 +2,9 @@ package ODXT
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
-	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -32,29 +32,47 @@ func MySQLSetup(tableName string) (*sql.DB, error) {
 	// 	return nil, err
 	// }
 
+	if err = CreateTable(db, tableName); err != nil {
+		log.Fatal(err)
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// CreateTable 创建保存加密索引的数据表 tableName，表已存在时只删除旧版本的 created_at 列
+func CreateTable(db *sql.DB, tableName string) error {
 	// 创建数据表tableName;如果表不存在则创建，如果表存在则不创建
-	// 表的结构为：id, address, value, alpha, created_at
+	// 表的结构为：id, address, value, alpha
 	// id 为自增主键
 	// address 为地址
 	// value 为值
 	// alpha 为alpha
-	// created_at 为创建时间
+	// 不记录每行的写入时间，避免服务器将同一次更新的条目关联起来
 	createTableSQL := fmt.Sprintf(`
 	CREATE TABLE IF NOT EXISTS %s (
 		id INT AUTO_INCREMENT PRIMARY KEY,
 		address VARCHAR(255) NOT NULL,
 		value VARCHAR(255) NOT NULL,
-		alpha VARCHAR(255) NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		alpha VARCHAR(255) NOT NULL
 	);`, tableName)
 
-	_, err = db.Exec(createTableSQL)
-	if err != nil {
-		log.Fatal(err)
-		return nil, err
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return err
 	}
+	return dropCreatedAt(db, tableName)
+}
 
-	return db, nil
+// dropCreatedAt 删除旧版本建表时添加的 created_at 列，已有的表不再记录每行的写入时间
+func dropCreatedAt(db *sql.DB, tableName string) error {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = 'created_at'`, tableName).Scan(&n)
+	if err != nil || n == 0 {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN created_at", tableName))
+	return err
 }
 
 // WriteUploadList writes the upload list to the MySQL database
@@ -100,6 +118,9 @@ func SearchStoken(db *sql.DB, address []string, tableName string) ([]SearchPaylo
 	var value, alpha string
 	for i, addr := range address {
 		err := db.QueryRow(querySQL, addr).Scan(&value, &alpha)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, addr)
+		}
 		if err != nil {
 			log.Println("error: Error in SearchStoken")
 			return nil, err
@@ -110,9 +131,32 @@ func SearchStoken(db *sql.DB, address []string, tableName string) ([]SearchPaylo
 	return result, nil
 }
 
+// DeleteAddresses 删除 address 对应的记录
+func DeleteAddresses(db *sql.DB, address []string, tableName string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("DELETE FROM %s WHERE address = ?", tableName))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, addr := range address {
+		if _, err = stmt.Exec(addr); err != nil {
+			return fmt.Errorf("error deleting data: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // 查看表的最新记录
 func ViewLatestRecords(db *sql.DB, tableName string, limit int) error {
-	query := fmt.Sprintf("SELECT * FROM %s ORDER BY created_at DESC LIMIT ?", tableName)
+	query := fmt.Sprintf("SELECT id, address, value, alpha FROM %s ORDER BY id DESC LIMIT ?", tableName)
 	rows, err := db.Query(query, limit)
 	if err != nil {
 		return err
@@ -123,13 +167,12 @@ func ViewLatestRecords(db *sql.DB, tableName string, limit int) error {
 	for rows.Next() {
 		var id int
 		var address, value, alpha string
-		var createdAt time.Time
-		err := rows.Scan(&id, &address, &value, &alpha, &createdAt)
+		err := rows.Scan(&id, &address, &value, &alpha)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("ID: %d, Address: %s, Value: %s, Alpha: %s, Created At: %s\n",
-			id, address, value, alpha, createdAt)
+		fmt.Printf("ID: %d, Address: %s, Value: %s, Alpha: %s\n",
+			id, address, value, alpha)
 	}
 
 	return nil
@@ -177,13 +220,13 @@ func GetRowCount(db *sql.DB, tableName string) (int, error) {
 	return count, nil
 }
 
-// GetRowCountAfterDate 获取指定日期之后添加的行数
-func GetRowCountAfterDate(db *sql.DB, tableName string, date time.Time) (int, error) {
-	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE created_at > ?", tableName)
+// GetRowCountAfterID 获取 id 大于 afterID 的行数，表中不再记录写入时间
+func GetRowCountAfterID(db *sql.DB, tableName string, afterID int) (int, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id > ?", tableName)
 	var count int
-	err := db.QueryRow(query, date).Scan(&count)
+	err := db.QueryRow(query, afterID).Scan(&count)
 	if err != nil {
-		return 0, fmt.Errorf("获取表 %s 在 %v 之后的行数时出错: %v", tableName, date, err)
+		return 0, fmt.Errorf("获取表 %s 中 id 大于 %d 的行数时出错: %v", tableName, afterID, err)
 	}
 	return count, nil
 }
@@ -203,3 +246,55 @@ func LoadMySQLDB() (*sql.DB, error) {
 
 	return db, nil
 }
+
+// CreateCounterTable 创建保存加密计数的数据表
+func CreateCounterTable(db *sql.DB, tableName string) error {
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		label VARCHAR(255) PRIMARY KEY,
+		value VARBINARY(255) NOT NULL
+	);`, tableName)
+	_, err := db.Exec(createTableSQL)
+	return err
+}
+
+// ReadCounters 读取 labels 对应的加密计数，不存在的标签不出现在结果中
+func ReadCounters(db *sql.DB, labels []string, tableName string) (map[string][]byte, error) {
+	querySQL := "SELECT value FROM " + tableName + " WHERE label = ?"
+	result := make(map[string][]byte, len(labels))
+	for _, label := range labels {
+		var value []byte
+		err := db.QueryRow(querySQL, label).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result[label] = value
+	}
+	return result, nil
+}
+
+// WriteCounters 写入或覆盖加密计数
+func WriteCounters(db *sql.DB, counters map[string][]byte, tableName string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("REPLACE INTO %s (label, value) VALUES (?, ?)", tableName))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for label, value := range counters {
+		if _, err = stmt.Exec(label, value); err != nil {
+			return fmt.Errorf("error writing counter: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
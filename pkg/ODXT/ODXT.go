@@ -2,12 +2,16 @@ package ODXT
 
 import (
 	"ConjunctiveSSE/pkg/Database"
+	"ConjunctiveSSE/pkg/leakage"
 	"ConjunctiveSSE/pkg/utils"
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -38,6 +42,33 @@ type ODXT struct {
 	XSet        *bloom.BloomFilter
 	PlaintextDB *mongo.Database
 	MySQLDB     *sql.DB
+	// Store 为服务器端加密索引，为 nil 时使用 MySQLDB 中以表名区分的数据表
+	Store Store
+	// HXT 不为 nil 时使用隐藏交叉标签模式，见 EnableHXT
+	HXT *HXT
+	// Checkpoints 为命名的历史快照，见 Checkpoint
+	Checkpoints map[string]*Checkpoint
+	// Counters 不为 nil 时 UpdateCnt 以加密形式保存在服务器端，见 FetchCounters
+	Counters CounterStore
+	// CounterTime 为累计的计数取回和写回耗时
+	CounterTime time.Duration
+	// Padding、PadStep、Epsilon 和 Delta 控制每个关键字的条目数填充，见 PadMode
+	Padding PadMode
+	PadStep int
+	Epsilon float64
+	Delta   float64
+	// Recorder 不为 nil 时记录服务器端查询观察到的信息，更新见 RecordingStore
+	Recorder *leakage.Recorder
+	// MaxConjuncts 大于 0 时每行 xtoken 以随机 xtoken 填充到 MaxConjuncts-1 个并打乱，见 padXtokens
+	MaxConjuncts int
+	// xtokenPos 为最近一次填充后每行真实 xtoken 的位置，解密时只统计这些位置的匹配
+	xtokenPos [][]int
+	// Verifier 不为 nil 时更新维护认证数据，查询结果可由 Verify 验证，见 EnableVerification
+	Verifier *Verifier
+	// ValueEncoding 为条目值的编码方式，默认为 XOREncoding
+	ValueEncoding ValueEncoding
+	// SkipDelXtags 为 true 时删除条目不写入 XSet，x-term 的删除由调用方在客户端过滤（见 BDXT）
+	SkipDelXtags bool
 }
 
 type UpdatePayload struct {
@@ -83,6 +114,24 @@ func ReadKeys(fileName string) [4][]byte {
 	return keys
 }
 
+// Init 初始化客户端状态和 XSet，不连接任何数据库，加密索引保存在 store 中
+func (odxt *ODXT) Init(keys [4][]byte, store Store) {
+	odxt.Keys = keys
+	odxt.UpdateCnt = make(map[string]int)
+	odxt.g = big.NewInt(65537)
+	odxt.p, _ = new(big.Int).SetString("69445180235231407255137142482031499329548634082242122837872648805446522657159", 10)
+	odxt.XSet = bloom.NewWithEstimates(1000000, 0.01)
+	odxt.Store = store
+}
+
+// store 返回保存加密索引的存储
+func (odxt *ODXT) store(tableName string) Store {
+	if odxt.Store != nil {
+		return odxt.Store
+	}
+	return &MySQLStore{DB: odxt.MySQLDB, TableName: tableName}
+}
+
 func (odxt *ODXT) DBSetup(dbName string, randomKey bool) error {
 	if randomKey {
 		// 生成4个32字节长度的随机私钥
@@ -132,12 +181,18 @@ func (odxt *ODXT) DBSetup(dbName string, randomKey bool) error {
 	return nil
 }
 
+// DBSetupFromFiles 从 c 阶段保存的 XSet 和 UpdateCnt 文件恢复客户端状态，
+// 文件需由当前 FormatVersion 生成，否则以 ErrFormatVersion 退出
 func (odxt *ODXT) DBSetupFromFiles(dbName string, xSetPath string, updateCntPath string) error {
 
 	// 读取私钥
 	odxt.Keys = ReadKeys("./cmd/ODXT/keys.txt")
 
-	// 读取 UpdateCnt
+	// 读取 UpdateCnt，状态文件需与当前的密钥派生方式一致
+	if err := checkFormat(updateCntPath); err != nil {
+		log.Fatal(err)
+		return err
+	}
 	var err error
 	odxt.UpdateCnt, err = utils.LoadUpdateCntFromFile(updateCntPath)
 	if err != nil {
@@ -184,9 +239,12 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 	// 初始化
 	uploadList := make([]UpdatePayload, 0, UploadListMaxLength+1)
 	encryptTimeList := make([]time.Duration, 0, 1000000)
+	counterTimeList := make([]time.Duration, 0, 1000000)
+	dummyList := make([]int, 0, 1000000)
 	keywordList := make([]string, 0, 1000000)
 	volumeList := make([]int, 0, 1000000)
 	clientStorageUpdateBytes := make([]int, 0, 1000000)
+	valueOverheadList := make([]int, 0, 1000000)
 
 	// 从MongoDB数据库中获取名为"id_keywords"的集合
 	collection := plaintextDB.Collection("id_keywords")
@@ -225,21 +283,25 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 		ids = utils.RemoveDuplicates(ids)
 		keyword := keywordId["k"].(string)
 
+		counterTime := odxt.CounterTime
 		encryptTime, keywordCipher, err := odxt.Encrypt(keyword, ids, 1)
 		if err != nil {
 			log.Fatal(err)
 		}
+		counterTimeList = append(counterTimeList, odxt.CounterTime-counterTime)
+		dummyList = append(dummyList, len(keywordCipher)-len(ids))
 
 		uploadList = append(uploadList, keywordCipher...)
 		encryptTimeList = append(encryptTimeList, encryptTime)
 		keywordList = append(keywordList, keyword)
 		volumeList = append(volumeList, len(keywordCipher))
 		clientStorageUpdateBytes = append(clientStorageUpdateBytes, CalculateUpdatePayloadSize(keywordCipher))
+		valueOverheadList = append(valueOverheadList, ValueOverhead(keywordCipher))
 
 		// 如果上传列表的长度达到最大限制， 则将其写入数据库
 		if len(uploadList) >= UploadListMaxLength {
 			// 写入文件
-			err = WriteUploadList(odxt.MySQLDB, uploadList, dbName)
+			err = odxt.store(dbName).Write(uploadList)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -252,12 +314,20 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 	// 如果上传列表不为空， 则将其写入数据库
 	if len(uploadList) > 0 {
 		// 写入文件
-		err = WriteUploadList(odxt.MySQLDB, uploadList, dbName)
+		err = odxt.store(dbName).Write(uploadList)
 		if err != nil {
 			log.Fatal(err)
 		}
 	}
 
+	// epoch 模式下上传剩余的缓存
+	if epochStore, ok := odxt.store(dbName).(*EpochStore); ok {
+		if err = epochStore.Flush(); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("epochs:", epochStore.Epochs, "batch size:", epochStore.BatchSize, "epoch dummy entries:", epochStore.Dummies)
+	}
+
 	saveTime := time.Now()
 	// 保存 XSet 到文件
 	err = utils.SaveBloomFilterToFile(odxt.XSet, filepath.Join("result", "Update", "ODXT", fmt.Sprintf("%s_%s_XSet.bin", dbName, saveTime.Format("2006-01-02_15-04-05"))))
@@ -265,8 +335,13 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 		log.Fatal(err)
 	}
 
-	// 保存 odxt.UpdateCnt 到文件
-	err = utils.SaveUpdateCntToFile(odxt.UpdateCnt, filepath.Join("result", "Update", "ODXT", fmt.Sprintf("%s_%s_UpdateCnt.json", dbName, saveTime.Format("2006-01-02_15-04-05"))))
+	// 保存 odxt.UpdateCnt 及其格式版本到文件
+	updateCntPath := filepath.Join("result", "Update", "ODXT", fmt.Sprintf("%s_%s_UpdateCnt.json", dbName, saveTime.Format("2006-01-02_15-04-05")))
+	err = utils.SaveUpdateCntToFile(odxt.UpdateCnt, updateCntPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = saveFormat(updateCntPath)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -276,11 +351,63 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 
 	// 定义结果表头
 	resultHeader := []string{"keyword", "volume", "addTime", "storageUpdateBytes"}
+	if odxt.Counters != nil {
+		resultHeader = append(resultHeader, "counterTime")
+	}
+	if odxt.Padding != NoPadding {
+		resultHeader = append(resultHeader, "padding", "dummyEntries", "paddingOverhead")
+	}
+	var epsilon, delta, width float64
+	if odxt.Padding == PadLaplace {
+		epsilon, delta, width = odxt.VolumeBound()
+		resultHeader = append(resultHeader, "epsilon", "delta", "volumeBoundWidth")
+	}
+	if odxt.ValueEncoding != XOREncoding {
+		resultHeader = append(resultHeader, "valueEncoding", "valueOverheadBytes")
+	}
 
 	// 将结果数据整理成表格形式
 	resultData := make([][]string, len(keywordList))
 	for i, keyword := range keywordList {
 		resultData[i] = []string{keyword, strconv.Itoa(volumeList[i]), encryptTimeList[i].String(), strconv.Itoa(clientStorageUpdateBytes[i])}
+		if odxt.Counters != nil {
+			resultData[i] = append(resultData[i], counterTimeList[i].String())
+		}
+		if odxt.Padding != NoPadding {
+			// paddingOverhead 为填充条目数与真实条目数之比
+			realEntries := volumeList[i] - dummyList[i]
+			resultData[i] = append(resultData[i], odxt.Padding.String(), strconv.Itoa(dummyList[i]),
+				strconv.FormatFloat(float64(dummyList[i])/float64(max(realEntries, 1)), 'f', 4, 64))
+		}
+		if odxt.Padding == PadLaplace {
+			resultData[i] = append(resultData[i], strconv.FormatFloat(epsilon, 'g', -1, 64), strconv.FormatFloat(delta, 'g', -1, 64),
+				strconv.FormatFloat(width, 'f', 2, 64))
+		}
+		if odxt.ValueEncoding != XOREncoding {
+			// valueOverheadBytes 为相对 XOR 编码多占用的存储字节数
+			resultData[i] = append(resultData[i], odxt.ValueEncoding.String(), strconv.Itoa(valueOverheadList[i]))
+		}
+	}
+
+	// 打印总的存储开销
+	if odxt.Padding != NoPadding {
+		totalVolume, totalDummies := 0, 0
+		for i := range keywordList {
+			totalVolume += volumeList[i]
+			totalDummies += dummyList[i]
+		}
+		fmt.Println("padding:", odxt.Padding, "dummy entries:", totalDummies, "real entries:", totalVolume-totalDummies,
+			"overhead:", float64(totalDummies)/float64(max(totalVolume-totalDummies, 1)))
+		if odxt.Padding == PadLaplace {
+			fmt.Printf("volume leakage bound: (%g, %g)-DP, true volume within [v-%.2f, v] w.p. >= 1-%g\n", epsilon, delta, width, delta)
+		}
+	}
+	if odxt.ValueEncoding != XOREncoding {
+		totalOverhead := 0
+		for _, overhead := range valueOverheadList {
+			totalOverhead += overhead
+		}
+		fmt.Println("value encoding:", odxt.ValueEncoding, "value overhead bytes:", totalOverhead)
 	}
 
 	// 将结果写入文件
@@ -291,12 +418,33 @@ func (odxt *ODXT) CiphertextGenPhase(dbName string) {
 }
 
 func (odxt *ODXT) Encrypt(keyword string, ids []string, operation int) (time.Duration, []UpdatePayload, error) {
-	kt, kx, ky, kz := odxt.Keys[0], odxt.Keys[1], odxt.Keys[2], odxt.Keys[3]
+	ky := odxt.Keys[2]
 	p, g := odxt.p, odxt.g
 
 	var encryptedTime time.Duration
 	keywordsCipher := make([]UpdatePayload, len(ids))
 
+	// 在修改计数之前拒绝会被截断的 id
+	for _, id := range ids {
+		if err := checkID(id); err != nil {
+			log.Println(err)
+			return encryptedTime, nil, err
+		}
+	}
+
+	kw, err := odxt.KeywordKey(keyword)
+	if err != nil {
+		log.Println(err)
+		return encryptedTime, nil, err
+	}
+
+	if odxt.Counters != nil {
+		if err := odxt.FetchCounters([]string{keyword}); err != nil {
+			log.Println(err)
+			return encryptedTime, nil, err
+		}
+	}
+
 	_, ok := odxt.UpdateCnt[keyword]
 	if !ok {
 		odxt.UpdateCnt[keyword] = 0
@@ -305,51 +453,96 @@ func (odxt *ODXT) Encrypt(keyword string, ids []string, operation int) (time.Dur
 	for i, id := range ids {
 		start := time.Now()
 		odxt.UpdateCnt[keyword]++
-		wWc := append([]byte(keyword), big.NewInt(int64(odxt.UpdateCnt[keyword])).Bytes()...)
+		wc := big.NewInt(int64(odxt.UpdateCnt[keyword])).Bytes()
 
-		// address = PRF(kt, w||wc||0)
-		address, err := utils.PrfF(kt, append(wWc, big.NewInt(int64(0)).Bytes()...))
+		// address = PRF(ka_w, wc)
+		base64Address, err := TokenAddress(kw.A, odxt.UpdateCnt[keyword])
 		if err != nil {
 			log.Println(err)
+			return encryptedTime, nil, err
 		}
 
-		// val = PRF(kt, w||wc||1) xor (id||op)
-		val, err := utils.PrfF(kt, append(wWc, big.NewInt(int64(1)).Bytes()...))
+		// val = PRF(kt_w, 1||wc) xor (id||op)，或以 PRF(kt_w, 2||wc) 为密钥、address 为附加数据的 AES-GCM 密文
+		k, err := odxt.ValueEncoding.entryKey(kw.T, odxt.UpdateCnt[keyword])
 		if err != nil {
 			log.Println(err)
 			return encryptedTime, nil, err
 		}
-		val, err = utils.BytesXORWithOp(val, []byte(id), operation)
+		val, err := odxt.ValueEncoding.encode(k, []byte(base64Address), []byte(id), operation)
 		if err != nil {
 			log.Println(err)
 			return encryptedTime, nil, err
 		}
 
-		// alpha = Fp(ky, id||op) * Fp(kz, w||wc)^-1
-		alpha, alpha1, err := utils.ComputeAlpha(ky, kz, []byte(id), operation, wWc, p, g)
+		// alpha = Fp(ky, id||op) * Fp(kz_w, wc)^-1
+		alpha, alpha1, err := utils.ComputeAlpha(ky, kw.Z, []byte(id), operation, wc, p, g)
 		if err != nil {
 			log.Println(err)
 			return encryptedTime, nil, err
 		}
 
 		// xtag = g^{Fp(Kx, w)*Fp(Ky, id||op)} mod p
-		C, err := utils.PrfFp(kx, []byte(keyword), p, g)
-		if err != nil {
-			log.Println(err)
-			return encryptedTime, nil, err
-		}
-		A := new(big.Int).Mul(C, alpha1)
+		A := new(big.Int).Mul(kw.X, alpha1)
 		xtag := new(big.Int).Exp(g, A, p)
 
 		encryptedTime += time.Since(start)
 
 		// Encoded the ciphertext
-		base64Address := base64.StdEncoding.EncodeToString(address)
 		base64Val := base64.StdEncoding.EncodeToString(val)
 		base64Alpha := base64.StdEncoding.EncodeToString(alpha.Bytes())
 
 		keywordsCipher[i] = UpdatePayload{base64Address, base64Val, base64Alpha}
-		odxt.XSet.Add(xtag.Bytes())
+		if odxt.SkipDelXtags && utils.Operation(operation) == utils.Del {
+			continue
+		}
+		if odxt.Verifier != nil {
+			if err := odxt.Verifier.addXtag(xtag.Bytes()); err != nil {
+				log.Println(err)
+				return encryptedTime, nil, err
+			}
+		} else {
+			odxt.XSet.Add(xtag.Bytes())
+		}
+	}
+
+	// HXT 模式下以新密钥整体重写 EXSet，服务器无法得知本次更新置位了哪些位置
+	if odxt.HXT != nil {
+		start := time.Now()
+		if err := odxt.HXT.rekey(odxt.XSet); err != nil {
+			log.Println(err)
+			return encryptedTime, nil, err
+		}
+		encryptedTime += time.Since(start)
+	}
+
+	// 以填充条目补齐到填充后的长度，服务器只能得知填充后的关键字数量
+	start := time.Now()
+	dummies, err := odxt.dummyCount(odxt.UpdateCnt[keyword])
+	if err != nil {
+		log.Println(err)
+		return encryptedTime, nil, err
+	}
+	for ; dummies > 0; dummies-- {
+		dummy, err := odxt.dummyEntry(keyword, kw)
+		if err != nil {
+			log.Println(err)
+			return encryptedTime, nil, err
+		}
+		keywordsCipher = append(keywordsCipher, dummy)
+	}
+	encryptedTime += time.Since(start)
+
+	if odxt.Verifier != nil {
+		for _, payload := range keywordsCipher {
+			odxt.Verifier.chain(keyword, payload.Address, payload.Val)
+		}
+	}
+
+	if odxt.Counters != nil {
+		if err := odxt.StoreCounters([]string{keyword}); err != nil {
+			log.Println(err)
+			return encryptedTime, nil, err
+		}
 	}
 
 	return encryptedTime, keywordsCipher, nil
@@ -407,19 +600,59 @@ func (odxt *ODXT) SearchPhase(tableName, fileName string) {
 	// For Test
 	keywordsList = keywordsList[:1]
 	// 循环搜索
+	hxtStatsList := make([]*HXTStats, 0, len(keywordsList)+1)
+	counterTimeList := make([]time.Duration, 0, len(keywordsList)+1)
+	verifyTimeList := make([]time.Duration, 0, len(keywordsList)+1)
+	proofBytesList := make([]int, 0, len(keywordsList)+1)
+	verifiedList := make([]bool, 0, len(keywordsList)+1)
+	tamperedList := make([]int, 0, len(keywordsList)+1)
 	for _, keywords := range keywordsList {
-		trapdoorTime, serverTime, sEOpList := odxt.Search(keywords, tableName)
+		counterTime := odxt.CounterTime
+		var trapdoorTime, serverTime time.Duration
+		var sEOpList []utils.SEOp
+		if odxt.HXT != nil {
+			var stats *HXTStats
+			var err error
+			trapdoorTime, serverTime, sEOpList, stats, err = odxt.HXTSearch(keywords, tableName)
+			if err != nil {
+				log.Fatal(err)
+			}
+			hxtStatsList = append(hxtStatsList, stats)
+		} else {
+			var err error
+			trapdoorTime, serverTime, sEOpList, err = odxt.Search(keywords, tableName)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
 
 		// 解密密文获得最终结果
 		start := time.Now()
 		sIdList, err := odxt.Decrypt(keywords, sEOpList)
-		if err != nil {
+		// 被篡改的条目已被丢弃，记录后继续
+		var tamperedErr *TamperedError
+		tampered := 0
+		if errors.As(err, &tamperedErr) {
+			log.Println("tampered entries:", strings.Join(keywords, "#"), err)
+			tampered = len(tamperedErr.J)
+		} else if err != nil {
 			log.Fatal(err)
 		}
+		tamperedList = append(tamperedList, tampered)
 		decryptTime := time.Since(start)
 		clientTime := trapdoorTime + decryptTime
+		if odxt.Verifier != nil {
+			verifyTime, err := odxt.Verify(keywords, sEOpList)
+			if err != nil {
+				log.Println("verification failed:", strings.Join(keywords, "#"), err)
+			}
+			verifyTimeList = append(verifyTimeList, verifyTime)
+			proofBytesList = append(proofBytesList, odxt.Verifier.Proof.Size())
+			verifiedList = append(verifiedList, err == nil)
+		}
 
 		// 将结果添加到结果列表
+		counterTimeList = append(counterTimeList, odxt.CounterTime-counterTime)
 		resultList = append(resultList, sIdList)
 		clientSearchTime = append(clientSearchTime, clientTime)
 		serverTimeList = append(serverTimeList, serverTime)
@@ -433,15 +666,45 @@ func (odxt *ODXT) SearchPhase(tableName, fileName string) {
 	}
 
 	// 设置结果文件的路径和名称
-	resultpath := filepath.Join("result", "Search", "ODXT", fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
+	schemeName := "ODXT"
+	if odxt.HXT != nil {
+		schemeName = "HXT"
+	}
+	resultpath := filepath.Join("result", "Search", schemeName, fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
 
 	// 定义结果表头
 	resultHeader := []string{"keyword", "clientSearchTime", "serverTime", "resultLength"}
+	if odxt.HXT != nil {
+		resultHeader = append(resultHeader, "shveKeyGenTime", "round2ServerTime", "tokenBytes", "pairBits", "revealedBits")
+	}
+	if odxt.Counters != nil {
+		resultHeader = append(resultHeader, "counterTime")
+	}
+	if odxt.Verifier != nil {
+		resultHeader = append(resultHeader, "verifyTime", "proofBytes", "verified")
+	}
+	if odxt.ValueEncoding != XOREncoding {
+		resultHeader = append(resultHeader, "tamperedEntries")
+	}
 
 	// 将结果数据整理成表格形式
 	resultData := make([][]string, len(resultList))
 	for i, keywords := range keywordsList {
 		resultData[i] = []string{strings.Join(keywords, "#"), clientSearchTime[i].String(), serverTimeList[i].String(), strconv.Itoa(resultLengthList[i])}
+		if odxt.HXT != nil {
+			stats := hxtStatsList[i]
+			resultData[i] = append(resultData[i], stats.KeyGenTime.String(), stats.Round2Time.String(), strconv.Itoa(stats.TokenBytes),
+				strconv.Itoa(stats.PairBits), strconv.Itoa(stats.RevealedBits))
+		}
+		if odxt.Counters != nil {
+			resultData[i] = append(resultData[i], counterTimeList[i].String())
+		}
+		if odxt.Verifier != nil {
+			resultData[i] = append(resultData[i], verifyTimeList[i].String(), strconv.Itoa(proofBytesList[i]), strconv.FormatBool(verifiedList[i]))
+		}
+		if odxt.ValueEncoding != XOREncoding {
+			resultData[i] = append(resultData[i], strconv.Itoa(tamperedList[i]))
+		}
 	}
 
 	// 将结果写入文件
@@ -452,15 +715,40 @@ func (odxt *ODXT) SearchPhase(tableName, fileName string) {
 }
 
 // Search 搜索，生成search token，并查询SQL数据库
-func (odxt *ODXT) Search(q []string, tableName string) (time.Duration, time.Duration, []utils.SEOp) {
+func (odxt *ODXT) Search(q []string, tableName string) (time.Duration, time.Duration, []utils.SEOp, error) {
+	if odxt.Counters != nil {
+		// 计数取回失败时不能用过期的计数查询，否则会返回不完整的结果
+		if err := odxt.FetchCounters(q); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
 	// 生成陷门
-	trapdoorTime, stokenList, xtokenList := odxt.Trapdoor(q)
+	trapdoorTime, stokenList, xtokenList, err := odxt.Trapdoor(q)
+	if err != nil {
+		return 0, 0, nil, err
+	}
 	fmt.Println("len(stokenList):", len(stokenList), "len(xtokenList):", len(xtokenList))
 
+	serverTime, sEOpList, err := odxt.ServerSearch(stokenList, xtokenList, tableName)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return trapdoorTime, serverTime, sEOpList, nil
+}
+
+// ServerSearch 服务器端根据 stokenList 查询加密索引，并用 xtokenList 测试 XSet。
+// 任一地址读取失败时返回错误，不会返回不完整的结果
+func (odxt *ODXT) ServerSearch(stokenList []string, xtokenList [][]string, tableName string) (time.Duration, []utils.SEOp, error) {
+	return odxt.serverSearch(odxt.XSet, stokenList, xtokenList, tableName)
+}
+
+// serverSearch 使用给定的 XSet 执行服务器端查询
+func (odxt *ODXT) serverSearch(xSet *bloom.BloomFilter, stokenList []string, xtokenList [][]string, tableName string) (time.Duration, []utils.SEOp, error) {
 	// 查询SQL数据库
-	tmpResult, err := SearchStoken(odxt.MySQLDB, stokenList, tableName)
+	tmpResult, err := odxt.store(tableName).Search(stokenList)
 	if err != nil {
-		log.Println(err)
+		return 0, nil, err
 	}
 
 	// fmt.Println("len(tmpResult):", len(tmpResult))
@@ -468,26 +756,40 @@ func (odxt *ODXT) Search(q []string, tableName string) (time.Duration, time.Dura
 	sEOpList := make([]utils.SEOp, len(stokenList))
 
 	start := time.Now()
+	if odxt.HXT != nil {
+		odxt.HXT.locations = make([][]uint64, len(stokenList))
+	}
+	if odxt.Verifier != nil {
+		odxt.Verifier.leaves = make(map[int]struct{})
+	}
 
 	// 搜索数据
 	for j, value := range tmpResult {
 		cnt := 1
+		hits := make([]bool, len(xtokenList[j]))
 		// 遍历 xtokenList
-		for _, xtoken := range xtokenList[j] {
+		for i, xtoken := range xtokenList[j] {
 			// 类型转换
 			xtokenInt, err := utils.Base64ToBigInt(xtoken)
 			if err != nil {
 				log.Println(err)
 			}
-			
+
 			alpha, err := utils.Base64ToBigInt(value.Alpha)
 			if err != nil {
 				log.Println(err)
 			}
 			// 判断 xtag 是否匹配
 			xtag := new(big.Int).Exp(xtokenInt, alpha, odxt.p)
-			if odxt.XSet.Test(xtag.Bytes()) {
+			if odxt.HXT != nil {
+				// HXT 模式下成员测试推迟到第二轮
+				odxt.HXT.collect(j, xtag.Bytes())
+			} else if xSet.Test(xtag.Bytes()) {
 				cnt++
+				hits[i] = true
+			}
+			if odxt.Verifier != nil {
+				odxt.Verifier.collect(xtag.Bytes())
 			}
 		}
 
@@ -495,20 +797,107 @@ func (odxt *ODXT) Search(q []string, tableName string) (time.Duration, time.Dura
 			J:    j + 1,
 			Sval: value.Value,
 			Cnt:  cnt,
+			Hits: hits,
 		}
 	}
+	if odxt.Verifier != nil {
+		odxt.Verifier.prove()
+	}
 
 	serverTime := time.Since(start)
-	return trapdoorTime, serverTime, sEOpList
+
+	// 记录服务器观察到的泄露
+	odxt.Recorder.Begin("ODXT", "search")
+	odxt.Recorder.Record(func(e *leakage.Event) {
+		e.Addresses = append(e.Addresses, stokenList...)
+		for j := range tmpResult {
+			e.Found = append(e.Found, j)
+			if odxt.HXT == nil {
+				e.XtagHits = append(e.XtagHits, sEOpList[j].Cnt-1)
+			}
+		}
+	})
+	if err := odxt.Recorder.End(); err != nil {
+		log.Println(err)
+	}
+
+	return serverTime, sEOpList, nil
 }
 
-// Trapdoor 生成陷门
-func (odxt *ODXT) Trapdoor(q []string) (time.Duration, []string, [][]string) {
-	// 读取密钥
+// kt 下派生密钥的域标签，输入的第一个字节互不相同，关键字密钥与其他用途的密钥不会碰撞
+const (
+	domainKeyword byte = 'w'
+	domainCounter byte = 'c'
+)
+
+// KeywordKey 关键字 w 的派生密钥，持有者可以在不知道主密钥的情况下生成 w 的陷门并解密其结果
+type KeywordKey struct {
+	T []byte   // kt_w = PRF(kt, 'w'||w)，用于值
+	A []byte   // ka_w = PRF(kt_w, labelAddress)，只用于地址，持有者可以枚举地址但不能解密
+	Z []byte   // kz_w = PRF(kz, w)，用于 alpha 和 xtoken
+	X *big.Int // Fp(kx, w)，w 作为 x-term 时使用
+}
+
+// KeywordKey 由主密钥派生关键字 w 的密钥
+func (odxt *ODXT) KeywordKey(keyword string) (*KeywordKey, error) {
 	kt, kx, kz := odxt.Keys[0], odxt.Keys[1], odxt.Keys[3]
-	counter, w1, st := math.MaxInt64, q[0], odxt.UpdateCnt
+	t, err := utils.PrfF(kt, append([]byte{domainKeyword}, keyword...))
+	if err != nil {
+		return nil, err
+	}
+	a, err := utils.PrfF(t, []byte{labelAddress})
+	if err != nil {
+		return nil, err
+	}
+	z, err := utils.PrfF(kz, []byte(keyword))
+	if err != nil {
+		return nil, err
+	}
+	x, err := utils.PrfFp(kx, []byte(keyword), odxt.p, odxt.g)
+	if err != nil {
+		return nil, err
+	}
+	return &KeywordKey{t, a, z, x}, nil
+}
+
+// kt_w 下派生密钥的域标签
+const (
+	labelAddress byte = iota // 地址密钥 ka_w = PRF(kt_w, 0)
+	labelValue               // XOR 编码的掩码 PRF(kt_w, 1||j)
+	labelAEAD                // AEAD 编码的条目密钥 PRF(kt_w, 2||j)
+)
 
-	// 选择查询频率最低的关键字
+// counterBytes j 的 8 字节大端编码。PRF 输入的长度固定，不同的 (标签, j) 不会得到相同的输入
+func counterBytes(j int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(j))
+}
+
+// entryKey 第 j 个条目用途为 label 的密钥 PRF(kt_w, label||j)
+func entryKey(t []byte, label byte, j int) ([]byte, error) {
+	return utils.PrfF(t, append([]byte{label}, counterBytes(j)...))
+}
+
+// Address 计算关键字第 j 次更新的密文地址 PRF(ka_w, j)
+func (odxt *ODXT) Address(keyword string, j int) (string, error) {
+	kw, err := odxt.KeywordKey(keyword)
+	if err != nil {
+		return "", err
+	}
+	return TokenAddress(kw.A, j)
+}
+
+// TokenAddress 由 ka_w 计算第 j 次更新的密文地址，持有 ka_w 者可计算任意 j 的地址
+func TokenAddress(a []byte, j int) (string, error) {
+	saddr, err := utils.PrfF(a, counterBytes(j))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(saddr), nil
+}
+
+// STerm 按计数 st 选择查询频率最低的关键字作为 s-term，返回该关键字及其计数
+func STerm(q []string, st map[string]int) (string, int) {
+	counter, w1 := math.MaxInt64, q[0]
 	for _, w := range q {
 		num := st[w]
 		if num < counter {
@@ -516,88 +905,145 @@ func (odxt *ODXT) Trapdoor(q []string) (time.Duration, []string, [][]string) {
 			counter = num
 		}
 	}
+	return w1, counter
+}
+
+// Trapdoor 生成陷门
+func (odxt *ODXT) Trapdoor(q []string) (time.Duration, []string, [][]string, error) {
+	return odxt.trapdoorWith(q, odxt.UpdateCnt)
+}
+
+// trapdoorWith 根据计数 st 生成陷门。关键字数超过 MaxConjuncts 的查询在生成任何令牌之前被拒绝，
+// 否则未填充的 xtoken 会暴露查询长度
+func (odxt *ODXT) trapdoorWith(q []string, st map[string]int) (time.Duration, []string, [][]string, error) {
+	odxt.xtokenPos = nil
+	if odxt.hideQuerySize() && len(q) > odxt.MaxConjuncts {
+		return 0, nil, nil, fmt.Errorf("%w: query has %d keywords, MaxConjuncts is %d", ErrTooManyConjuncts, len(q), odxt.MaxConjuncts)
+	}
+
+	start := time.Now()
+	w1, counter := STerm(q, st)
 
 	// 将q中的w1从q中删除
 	qWithoutW1 := utils.RemoveElement(q, w1)
 
-	// 初始化stokenList和xtokenList
+	kw1, err := odxt.KeywordKey(w1)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	xkeys := make([]*big.Int, len(qWithoutW1))
+	for i, wi := range qWithoutW1 {
+		kwi, err := odxt.KeywordKey(wi)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		xkeys[i] = kwi.X
+	}
+
+	stokenList, xtokenList := trapdoor(kw1, xkeys, counter, odxt.p, odxt.g)
+	if odxt.hideQuerySize() {
+		if err := odxt.padXtokens(xtokenList); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	trapdoorTime := time.Since(start)
+
+	return trapdoorTime, stokenList, xtokenList, nil
+}
+
+// trapdoor 由 s-term 的密钥 kw1 和各 x-term 的 Fp(kx, wi) 生成 stokenList 和 xtokenList
+func trapdoor(kw1 *KeywordKey, xkeys []*big.Int, counter int, p, g *big.Int) ([]string, [][]string) {
 	stokenList := make([]string, counter)
 	xtokenList := make([][]string, counter)
-	for i := range xtokenList {
-		xtokenList[i] = make([]string, len(qWithoutW1))
-	}
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
 
-	start := time.Now()
 	for j := 0; j < counter; j++ {
-		saddr, err := utils.PrfF(kt, append(append([]byte(w1), big.NewInt(int64(j+1)).Bytes()...), big.NewInt(int64(0)).Bytes()...))
+		jb := big.NewInt(int64(j + 1)).Bytes()
+		stoken, err := TokenAddress(kw1.A, j+1)
 		if err != nil {
 			fmt.Println(err)
 		}
-		stokenList[j] = base64.StdEncoding.EncodeToString(saddr)
-
-		for i, wi := range qWithoutW1 {
-			xtoken1, _ := utils.PrfFp(kx, []byte(wi), odxt.p, odxt.g)
-			xtoken2, _ := utils.PrfFp(kz, append([]byte(w1), big.NewInt(int64(j+1)).Bytes()...), odxt.p, odxt.g)
-			xtokenHead := new(big.Int).Mod(new(big.Int).Mul(xtoken1, xtoken2), odxt.p)
-			xtoken := new(big.Int).Exp(odxt.g, xtokenHead, odxt.p)
+		stokenList[j] = stoken
+
+		xtokenList[j] = make([]string, len(xkeys))
+		xtoken2, _ := utils.PrfFp(kw1.Z, jb, p, g)
+		for i, xtoken1 := range xkeys {
+			// 指数在模 p-1 的群中运算，与 ComputeAlpha 保持一致
+			xtokenHead := new(big.Int).Mod(new(big.Int).Mul(xtoken1, xtoken2), pMinus1)
+			xtoken := new(big.Int).Exp(g, xtokenHead, p)
 			xtokenList[j][i] = base64.StdEncoding.EncodeToString(xtoken.Bytes())
 		}
-
-		// // 打乱切片中的元素
-		// mrand.Shuffle(len(xtokenList[j]), func(i, k int) {
-		// 	xtokenList[j][i], xtokenList[j][k] = xtokenList[j][k], xtokenList[j][i]
-		// 	})
 	}
 
-	trapdoorTime := time.Since(start)
-
-	return trapdoorTime, stokenList, xtokenList
+	return stokenList, xtokenList
 }
 
 // Decrypt 解密
 func (odxt *ODXT) Decrypt(q []string, sEOpList []utils.SEOp) ([]string, error) {
-	kt := odxt.Keys[0]
-	counter, w1, st := 1000000, q[0], odxt.UpdateCnt
+	return odxt.decryptWith(q, odxt.UpdateCnt, sEOpList)
+}
 
-	// 选择查询频率最低的关键字
-	for _, w := range q {
-		num := st[w]
-		if num < counter {
-			w1 = w
-			counter = num
-		}
+// decryptWith 按计数 st 选择 s-term 并解密
+func (odxt *ODXT) decryptWith(q []string, st map[string]int, sEOpList []utils.SEOp) ([]string, error) {
+	w1, _ := STerm(q, st)
+	kw1, err := odxt.KeywordKey(w1)
+	if err != nil {
+		return nil, err
 	}
+	return decrypt(kw1, len(q), odxt.realXtokens(), odxt.ValueEncoding, sEOpList)
+}
 
+// decrypt 用 s-term 的密钥 kw1 解密服务器返回的结果，n 为查询关键字数。
+// pos 不为 nil 时 xtoken 中含有填充，只统计真实 xtoken 的匹配，见 matches。
+// 未通过认证的条目被丢弃，并以 *TamperedError 报告。
+func decrypt(kw1 *KeywordKey, n int, pos [][]int, enc ValueEncoding, sEOpList []utils.SEOp) ([]string, error) {
 	sIdList := make([]string, 0, len(sEOpList))
+	var tampered []int
 	for _, sEOp := range sEOpList {
-		j, sval, cnt := sEOp.J, sEOp.Sval, sEOp.Cnt
-		w1Andj := append(append([]byte(w1), big.NewInt(int64(j)).Bytes()...), big.NewInt(int64(1)).Bytes()...)
-		tmp, err := utils.PrfF(kt, w1Andj)
-		if err != nil {
-			fmt.Println(err)
-			return nil, err
+		sId, op, err := decryptEntry(kw1, sEOp.J, sEOp.Sval, enc)
+		if errors.Is(err, errTampered) {
+			tampered = append(tampered, sEOp.J)
+			continue
 		}
-		id := make([]byte, 31)
-		val, err := base64.StdEncoding.DecodeString(sval)
 		if err != nil {
-			log.Fatal(err)
+			log.Println(err)
 			return nil, err
 		}
-		for i := 0; i < 31; i++ {
-			id[i] = tmp[i] ^ val[i]
-		}
-		var op = utils.Operation(tmp[31] ^ val[31])
-		log.Println("op=", op, "cnt=", cnt)
-		if op == utils.Add && cnt == len(q) {
-			sIdList = append(sIdList, base64.StdEncoding.EncodeToString(id))
-		} else if op == utils.Del && cnt > 0 {
-			sIdList = utils.RemoveElementFromSlice(sIdList, base64.StdEncoding.EncodeToString(id))
+		if op == utils.Add && matches(sEOp, pos) == n {
+			sIdList = append(sIdList, sId)
+		} else if op == utils.Del && sEOp.Cnt > 0 {
+			sIdList = utils.RemoveElementFromSlice(sIdList, sId)
 		}
 	}
 
+	if len(tampered) > 0 {
+		return sIdList, &TamperedError{J: tampered}
+	}
 	return sIdList, nil
 }
 
+// decryptEntry 解密 s-term 的第 j 个条目，得到 id 和 op
+func decryptEntry(kw1 *KeywordKey, j int, sval string, enc ValueEncoding) (string, utils.Operation, error) {
+	k, err := enc.entryKey(kw1.T, j)
+	if err != nil {
+		return "", 0, err
+	}
+	address, err := TokenAddress(kw1.A, j)
+	if err != nil {
+		return "", 0, err
+	}
+	val, err := base64.StdEncoding.DecodeString(sval)
+	if err != nil {
+		return "", 0, errTampered
+	}
+	id, op, err := enc.decode(k, []byte(address), val)
+	if err != nil {
+		return "", 0, err
+	}
+	// id 在加密时以 0 填充到 31 字节
+	return string(bytes.TrimRight(id, "\x00")), op, nil
+}
+
 // CalculateUpdatePayloadSize 计算[]UpdatePayload的字节大小
 func CalculateUpdatePayloadSize(payloads []UpdatePayload) int {
 	size := 0
@@ -0,0 +1,96 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/leakage"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrNotFound 查询的地址不在加密索引中
+var ErrNotFound = errors.New("address not found")
+
+// Store 服务器端加密索引 (address -> value, alpha) 的存储
+type Store interface {
+	// Write 写入一批密文
+	Write(uploadList []UpdatePayload) error
+	// Search 按顺序查询 address 对应的密文，任一地址不存在时返回包装了 ErrNotFound 的错误
+	Search(address []string) ([]SearchPayload, error)
+	// Delete 删除 address 对应的密文
+	Delete(address []string) error
+}
+
+// MySQLStore 使用 MySQL 数据表保存加密索引
+type MySQLStore struct {
+	DB        *sql.DB
+	TableName string
+}
+
+func (s *MySQLStore) Write(uploadList []UpdatePayload) error {
+	return WriteUploadList(s.DB, uploadList, s.TableName)
+}
+
+func (s *MySQLStore) Search(address []string) ([]SearchPayload, error) {
+	return SearchStoken(s.DB, address, s.TableName)
+}
+
+func (s *MySQLStore) Delete(address []string) error {
+	return DeleteAddresses(s.DB, address, s.TableName)
+}
+
+// MemoryStore 在内存中保存加密索引，用于测试和不依赖数据库的实验
+type MemoryStore map[string]SearchPayload
+
+func NewMemoryStore() MemoryStore {
+	return make(MemoryStore)
+}
+
+func (s MemoryStore) Write(uploadList []UpdatePayload) error {
+	for _, payload := range uploadList {
+		if payload.Address == "" || payload.Val == "" || payload.Alpha == "" {
+			return fmt.Errorf("invalid payload data: %v", payload)
+		}
+		s[payload.Address] = SearchPayload{Value: payload.Val, Alpha: payload.Alpha}
+	}
+	return nil
+}
+
+func (s MemoryStore) Search(address []string) ([]SearchPayload, error) {
+	result := make([]SearchPayload, len(address))
+	for i, addr := range address {
+		payload, ok := s[addr]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, addr)
+		}
+		result[i] = payload
+	}
+	return result, nil
+}
+
+func (s MemoryStore) Delete(address []string) error {
+	for _, addr := range address {
+		delete(s, addr)
+	}
+	return nil
+}
+
+// RecordingStore 将每次写入的地址作为一次更新事件记录到 Recorder，查询在 ODXT.ServerSearch 中记录
+type RecordingStore struct {
+	Store
+	Recorder *leakage.Recorder
+	Scheme   string
+}
+
+func (s *RecordingStore) Write(uploadList []UpdatePayload) error {
+	s.Recorder.Begin(s.Scheme, "update")
+	s.Recorder.Record(func(e *leakage.Event) {
+		for _, payload := range uploadList {
+			e.Addresses = append(e.Addresses, payload.Address)
+		}
+	})
+	if err := s.Recorder.End(); err != nil {
+		log.Println(err)
+	}
+	return s.Store.Write(uploadList)
+}
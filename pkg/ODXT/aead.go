@@ -0,0 +1,126 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ValueEncoding 条目值 val 的编码方式，需在第一次 Encrypt 之前设置
+type ValueEncoding int
+
+const (
+	// XOREncoding val = PRF(kt_w, 1||wc) xor (id||op)，服务器可以不被察觉地翻转 id 或 op 的位
+	XOREncoding ValueEncoding = iota
+	// AEADEncoding val = AES-256-GCM(PRF(kt_w, 2||wc), id||op)，以条目地址为附加数据，
+	// 每个条目使用独立的密钥，解密时拒绝被篡改或被移动到其他地址的条目
+	AEADEncoding
+)
+
+// errTampered 条目未通过 AEAD 认证
+var errTampered = errors.New("entry failed authentication")
+
+// ErrInvalidID id 超过 31 字节或以 0 字节结尾，解密得到的 id 与加密时不同，
+// alpha 和 xtag 也无法由解密结果重新计算（见 Verify）
+var ErrInvalidID = errors.New("id must be at most 31 bytes and must not end with a zero byte")
+
+// checkID 检查 id 能否由条目值原样解密
+func checkID(id string) error {
+	if len(id) > 31 || strings.HasSuffix(id, "\x00") {
+		return fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	return nil
+}
+
+func (e ValueEncoding) String() string {
+	if e == AEADEncoding {
+		return "aead"
+	}
+	return "xor"
+}
+
+// valueSize 编码后 val 的字节数：id 以 0 填充到 31 字节后附加 1 字节 op，AEAD 另有 16 字节标签
+func (e ValueEncoding) valueSize() int {
+	if e == AEADEncoding {
+		return 32 + 16
+	}
+	return 32
+}
+
+// ValueOverhead 相对于 XOREncoding，payloads 中 val 多占用的存储字节数（base64 编码后）
+func ValueOverhead(payloads []UpdatePayload) int {
+	overhead := 0
+	for _, payload := range payloads {
+		overhead += len(payload.Val) - base64.StdEncoding.EncodedLen(XOREncoding.valueSize())
+	}
+	return overhead
+}
+
+// entryKey 第 j 个条目的编码密钥，两种编码使用不同的域标签
+func (e ValueEncoding) entryKey(t []byte, j int) ([]byte, error) {
+	if e == AEADEncoding {
+		return entryKey(t, labelAEAD, j)
+	}
+	return entryKey(t, labelValue, j)
+}
+
+// gcm 每个条目的密钥只加密一次，因此使用固定的全零 nonce
+func gcm(k []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encode 用条目密钥 k 编码 (id, op)，AEADEncoding 下以 address 为附加数据
+func (e ValueEncoding) encode(k, address, id []byte, op int) ([]byte, error) {
+	if e != AEADEncoding {
+		return utils.BytesXORWithOp(k, id, op)
+	}
+	plaintext, err := utils.BytesXORWithOp(make([]byte, 32), id, op)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := gcm(k)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, make([]byte, aead.NonceSize()), plaintext, address), nil
+}
+
+// decode 解码 val 得到以 0 填充的 id 和 op，AEADEncoding 下认证失败时返回 errTampered
+func (e ValueEncoding) decode(k, address, val []byte) ([]byte, utils.Operation, error) {
+	if len(val) != e.valueSize() {
+		return nil, 0, errTampered
+	}
+	plaintext := make([]byte, 32)
+	if e == AEADEncoding {
+		aead, err := gcm(k)
+		if err != nil {
+			return nil, 0, err
+		}
+		if plaintext, err = aead.Open(nil, make([]byte, aead.NonceSize()), val, address); err != nil {
+			return nil, 0, errTampered
+		}
+	} else {
+		for i := range plaintext {
+			plaintext[i] = k[i] ^ val[i]
+		}
+	}
+	return plaintext[:31], utils.Operation(plaintext[31]), nil
+}
+
+// TamperedError Decrypt 发现并丢弃了未通过认证的条目，J 为这些条目在 s-term 列表中的序号。
+// 与之一同返回的结果只包含通过认证的条目。
+type TamperedError struct {
+	J []int
+}
+
+func (e *TamperedError) Error() string {
+	return fmt.Sprintf("%d tampered entries rejected: %v", len(e.J), e.J)
+}
@@ -0,0 +1,114 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestAEADEncoding(t *testing.T) {
+	store := NewMemoryStore()
+	odxt := newTestODXT(t, store)
+	odxt.ValueEncoding = AEADEncoding
+	update := func(op utils.Operation, w string, ids ...string) {
+		// 每个条目比 XOR 编码多 16 字节标签，base64 编码后多 20 字节
+		payloads := odxt.update(op, w, ids...)
+		if got, want := ValueOverhead(payloads), 20*len(payloads); got != want {
+			t.Errorf("ValueOverhead = %d, want %d", got, want)
+		}
+	}
+	update(utils.Add, "a", "id1", "id2", "id3")
+	update(utils.Add, "b", "id1", "id3")
+	update(utils.Del, "a", "id2")
+
+	if ids, want := odxt.search("a", "b"), []string{"id1", "id3"}; !slices.Equal(ids, want) {
+		t.Errorf("Search(a, b) = %v, want %v", ids, want)
+	}
+
+	// 服务器翻转第 2 个条目 val 中的一位
+	kw, err := odxt.KeywordKey("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := TokenAddress(kw.A, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := store[address]
+	val, err := base64.StdEncoding.DecodeString(payload.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	val[0] ^= 1
+	payload.Value = base64.StdEncoding.EncodeToString(val)
+	store[address] = payload
+
+	_, _, sEOpList, err := odxt.Search([]string{"a"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := odxt.Decrypt([]string{"a"}, sEOpList)
+	slices.Sort(ids)
+	var tamperedErr *TamperedError
+	if !errors.As(err, &tamperedErr) {
+		t.Fatalf("Search(a) error = %v, want *TamperedError", err)
+	}
+	if want := []int{2}; !slices.Equal(tamperedErr.J, want) {
+		t.Errorf("TamperedError.J = %v, want %v", tamperedErr.J, want)
+	}
+	// 被丢弃的是 id2 的添加条目，其删除条目仍然生效
+	if want := []string{"id1", "id3"}; !slices.Equal(ids, want) {
+		t.Errorf("Search(a) = %v, want %v", ids, want)
+	}
+}
+
+func TestEntryKeysDistinct(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	kw, err := odxt.KeywordKey("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 变长编码下 PRF(kt_w, 257||0) 与 PRF(kt_w, 1||1) 的输入相同
+	key, err := AEADEncoding.entryKey(kw.T, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range [][]byte{kw.T, kw.A} {
+		address, err := TokenAddress(k, 257)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if address == base64.StdEncoding.EncodeToString(key) {
+			t.Error("address of entry 257 equals the AEAD key of entry 1")
+		}
+	}
+
+	seen := make(map[string]string)
+	for j := 1; j <= 600; j++ {
+		address, err := TokenAddress(kw.A, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mask, err := XOREncoding.entryKey(kw.T, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := AEADEncoding.entryKey(kw.T, j)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, v := range map[string]string{
+			"address": address,
+			"mask":    base64.StdEncoding.EncodeToString(mask),
+			"key":     base64.StdEncoding.EncodeToString(key),
+		} {
+			if prev, ok := seen[v]; ok {
+				t.Fatalf("%s of entry %d collides with %s", name, j, prev)
+			}
+			seen[v] = fmt.Sprintf("%s of entry %d", name, j)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// Trapdoor 只遍历 j = 1..UpdateCnt[w1]，因此持有旧计数的客户端只会检索到当时已有的 s-term 条目。
+// Checkpoint 在此基础上同时保存当时的 XSet（服务器端），使 x-term 的匹配也停留在快照时刻，
+// 对不断增长的索引可以重复执行完全相同的查询。
+
+var (
+	// ErrCheckpointHXT HXT 模式下 EXSet 不保存历史版本
+	ErrCheckpointHXT = errors.New("search as of checkpoint is not supported in HXT mode")
+	// ErrCheckpointCounters 计数外包时 UpdateCnt 只缓存最近取回的关键字，不能作为快照
+	ErrCheckpointCounters = errors.New("checkpoint is not supported with outsourced counters")
+)
+
+// Checkpoint 一个命名快照
+type Checkpoint struct {
+	UpdateCnt map[string]int     // 客户端保存的计数快照
+	XSet      *bloom.BloomFilter // 服务器端保存的 XSet 快照
+	Time      time.Time
+}
+
+// Checkpoint 以 name 记录当前的 UpdateCnt 和 XSet，同名快照会被覆盖
+func (odxt *ODXT) Checkpoint(name string) error {
+	if odxt.Counters != nil {
+		return ErrCheckpointCounters
+	}
+	if odxt.Checkpoints == nil {
+		odxt.Checkpoints = make(map[string]*Checkpoint)
+	}
+	odxt.Checkpoints[name] = &Checkpoint{
+		UpdateCnt: maps.Clone(odxt.UpdateCnt),
+		XSet:      odxt.XSet.Copy(),
+		Time:      time.Now(),
+	}
+	return nil
+}
+
+// SearchAsOf 在快照 name 上执行查询并解密，结果与在快照时刻执行 Search 相同
+func (odxt *ODXT) SearchAsOf(name string, q []string, tableName string) (time.Duration, time.Duration, []string, error) {
+	cp, ok := odxt.Checkpoints[name]
+	if !ok {
+		return 0, 0, nil, fmt.Errorf("checkpoint %q not found", name)
+	}
+	if odxt.HXT != nil {
+		return 0, 0, nil, ErrCheckpointHXT
+	}
+
+	trapdoorTime, stokenList, xtokenList, err := odxt.trapdoorWith(q, cp.UpdateCnt)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	serverTime, sEOpList, err := odxt.serverSearch(cp.XSet, stokenList, xtokenList, tableName)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	start := time.Now()
+	ids, err := odxt.decryptWith(q, cp.UpdateCnt, sEOpList)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return trapdoorTime + time.Since(start), serverTime, ids, nil
+}
+
+// SaveCheckpoint 将快照 name 的计数和 XSet 保存到 dir
+func (odxt *ODXT) SaveCheckpoint(name, dir string) error {
+	cp, ok := odxt.Checkpoints[name]
+	if !ok {
+		return fmt.Errorf("checkpoint %q not found", name)
+	}
+	prefix := filepath.Join(dir, fmt.Sprintf("%s_%s", name, cp.Time.Format("2006-01-02_15-04-05")))
+	if err := utils.SaveUpdateCntToFile(cp.UpdateCnt, prefix+"_UpdateCnt.json"); err != nil {
+		return err
+	}
+	if err := saveFormat(prefix + "_UpdateCnt.json"); err != nil {
+		return err
+	}
+	return utils.SaveBloomFilterToFile(cp.XSet, prefix+"_XSet.bin")
+}
+
+// LoadCheckpoint 从文件读取快照并以 name 记录
+func (odxt *ODXT) LoadCheckpoint(name, xSetPath, updateCntPath string) error {
+	if err := checkFormat(updateCntPath); err != nil {
+		return err
+	}
+	updateCnt, err := utils.LoadUpdateCntFromFile(updateCntPath)
+	if err != nil {
+		return err
+	}
+	xSet, err := utils.LoadBloomFilterFromFile(xSetPath)
+	if err != nil {
+		return err
+	}
+	if odxt.Checkpoints == nil {
+		odxt.Checkpoints = make(map[string]*Checkpoint)
+	}
+	odxt.Checkpoints[name] = &Checkpoint{UpdateCnt: updateCnt, XSet: xSet, Time: time.Now()}
+	return nil
+}
@@ -0,0 +1,84 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSearchAsOf(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	search := func(name string, q ...string) []string {
+		_, _, ids, err := odxt.SearchAsOf(name, q, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	queries := [][]string{{"a"}, {"b"}, {"a", "b"}, {"b", "a"}}
+
+	// 每个快照时刻的实时查询结果
+	want := make(map[string][][]string)
+	checkpoint := func(name string) {
+		if err := odxt.Checkpoint(name); err != nil {
+			t.Fatal(err)
+		}
+		for _, q := range queries {
+			want[name] = append(want[name], odxt.search(q...))
+		}
+	}
+
+	odxt.update(utils.Add, "a", "id1", "id2")
+	odxt.update(utils.Add, "b", "id1")
+	checkpoint("v1")
+	odxt.update(utils.Add, "a", "id3")
+	odxt.update(utils.Add, "b", "id2", "id3")
+	checkpoint("v2")
+	odxt.update(utils.Del, "a", "id1", "id3")
+	odxt.update(utils.Add, "b", "id4")
+	odxt.update(utils.Add, "a", "id4")
+
+	for _, name := range []string{"v1", "v2"} {
+		for i, q := range queries {
+			if got := search(name, q...); !slices.Equal(got, want[name][i]) {
+				t.Errorf("SearchAsOf(%s, %v) = %v, want %v", name, q, got, want[name][i])
+			}
+		}
+	}
+	if got := search("v1", "a", "b"); !slices.Equal(got, []string{"id1"}) {
+		t.Errorf("SearchAsOf(v1, [a b]) = %v, want [id1]", got)
+	}
+
+	if _, _, _, err := odxt.SearchAsOf("v3", []string{"a"}, ""); err == nil {
+		t.Error("SearchAsOf(v3) succeeded, want error")
+	}
+}
+
+func TestCheckpointFormat(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1", "id2")
+	if err := odxt.Checkpoint("v1"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := odxt.SaveCheckpoint("v1", dir); err != nil {
+		t.Fatal(err)
+	}
+	prefix := filepath.Join(dir, "v1_"+odxt.Checkpoints["v1"].Time.Format("2006-01-02_15-04-05"))
+	if err := odxt.LoadCheckpoint("v2", prefix+"_XSet.bin", prefix+"_UpdateCnt.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有格式文件的状态文件来自基线的密钥派生方式
+	if err := os.Remove(prefix + "_Format.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := odxt.LoadCheckpoint("v3", prefix+"_XSet.bin", prefix+"_UpdateCnt.json"); !errors.Is(err, ErrFormatVersion) {
+		t.Errorf("LoadCheckpoint without format file error = %v, want %v", err, ErrFormatVersion)
+	}
+}
@@ -0,0 +1,159 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// 无状态客户端：每个关键字的更新计数以 label = PRF(kl, w)、value = AES-GCM(ke, cnt) 的形式保存在服务器端，
+// 每次更新或查询前取回相关关键字的计数，更新后写回。UpdateCnt 只作为本次操作的缓存，
+// 共享同一组主密钥的多个设备无需同步客户端文件即可使用同一个索引。
+
+// CounterStore 服务器端加密计数的存储
+type CounterStore interface {
+	// Get 返回 labels 中存在的加密计数
+	Get(labels []string) (map[string][]byte, error)
+	// Put 写入或覆盖加密计数
+	Put(counters map[string][]byte) error
+}
+
+// MemoryCounterStore 在内存中保存加密计数
+type MemoryCounterStore map[string][]byte
+
+func NewMemoryCounterStore() MemoryCounterStore {
+	return make(MemoryCounterStore)
+}
+
+func (s MemoryCounterStore) Get(labels []string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(labels))
+	for _, label := range labels {
+		if c, ok := s[label]; ok {
+			result[label] = c
+		}
+	}
+	return result, nil
+}
+
+func (s MemoryCounterStore) Put(counters map[string][]byte) error {
+	for label, c := range counters {
+		s[label] = c
+	}
+	return nil
+}
+
+// MySQLCounterStore 使用 MySQL 数据表保存加密计数
+type MySQLCounterStore struct {
+	DB        *sql.DB
+	TableName string
+}
+
+func (s *MySQLCounterStore) Get(labels []string) (map[string][]byte, error) {
+	return ReadCounters(s.DB, labels, s.TableName)
+}
+
+func (s *MySQLCounterStore) Put(counters map[string][]byte) error {
+	return WriteCounters(s.DB, counters, s.TableName)
+}
+
+// counterKeys 由主密钥派生计数的标签密钥和加密密钥，输入以 domainCounter 开头，
+// 与任何关键字的 kt_w 都不相同，委托给读者的关键字密钥不会泄露计数密钥
+func (odxt *ODXT) counterKeys() ([]byte, cipher.AEAD, error) {
+	kl, err := utils.PrfF(odxt.Keys[0], append([]byte{domainCounter}, "label"...))
+	if err != nil {
+		return nil, nil, err
+	}
+	ke, err := utils.PrfF(odxt.Keys[0], append([]byte{domainCounter}, "enc"...))
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := aes.NewCipher(ke)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return kl, aead, nil
+}
+
+// counterLabels 计算关键字计数在服务器端的标签
+func counterLabels(kl []byte, keywords []string) ([]string, error) {
+	labels := make([]string, len(keywords))
+	for i, w := range keywords {
+		label, err := utils.PrfF(kl, []byte(w))
+		if err != nil {
+			return nil, err
+		}
+		labels[i] = base64.StdEncoding.EncodeToString(label)
+	}
+	return labels, nil
+}
+
+// FetchCounters 从服务器取回 keywords 的计数并写入 UpdateCnt，服务器端不存在的计数为 0
+func (odxt *ODXT) FetchCounters(keywords []string) error {
+	start := time.Now()
+	defer func() { odxt.CounterTime += time.Since(start) }()
+
+	kl, aead, err := odxt.counterKeys()
+	if err != nil {
+		return err
+	}
+	labels, err := counterLabels(kl, keywords)
+	if err != nil {
+		return err
+	}
+	counters, err := odxt.Counters.Get(labels)
+	if err != nil {
+		return err
+	}
+	for i, w := range keywords {
+		c, ok := counters[labels[i]]
+		if !ok {
+			delete(odxt.UpdateCnt, w)
+			continue
+		}
+		if len(c) < aead.NonceSize() {
+			return errors.New("malformed encrypted counter")
+		}
+		// 以标签作为附加数据，防止服务器交换不同关键字的计数
+		plain, err := aead.Open(nil, c[:aead.NonceSize()], c[aead.NonceSize():], []byte(labels[i]))
+		if err != nil {
+			return err
+		}
+		odxt.UpdateCnt[w] = int(binary.BigEndian.Uint64(plain))
+	}
+	return nil
+}
+
+// StoreCounters 加密 keywords 的计数并写回服务器，每次使用新的随机 nonce
+func (odxt *ODXT) StoreCounters(keywords []string) error {
+	start := time.Now()
+	defer func() { odxt.CounterTime += time.Since(start) }()
+
+	kl, aead, err := odxt.counterKeys()
+	if err != nil {
+		return err
+	}
+	labels, err := counterLabels(kl, keywords)
+	if err != nil {
+		return err
+	}
+	counters := make(map[string][]byte, len(keywords))
+	for i, w := range keywords {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		plain := binary.BigEndian.AppendUint64(nil, uint64(odxt.UpdateCnt[w]))
+		counters[labels[i]] = aead.Seal(nonce, nonce, plain, []byte(labels[i]))
+	}
+	return odxt.Counters.Put(counters)
+}
@@ -0,0 +1,78 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestOutsourcedCounters(t *testing.T) {
+	store, counters := NewMemoryStore(), NewMemoryCounterStore()
+
+	// 两个设备共享主密钥和服务器端状态，各自不保存计数
+	dev1, dev2 := newTestODXT(t, store), newTestODXT(t, store)
+	dev2.XSet = dev1.XSet
+	dev1.Counters, dev2.Counters = counters, counters
+	update := func(dev *testODXT, op utils.Operation, w string, ids ...string) {
+		dev.update(op, w, ids...)
+		// 操作结束后丢弃本地缓存
+		dev.UpdateCnt = make(map[string]int)
+	}
+
+	update(dev1, utils.Add, "a", "id1", "id2")
+	update(dev2, utils.Add, "a", "id3")
+	update(dev2, utils.Add, "b", "id1", "id3")
+	update(dev1, utils.Del, "b", "id1")
+
+	if got, want := dev2.search("a"), []string{"id1", "id2", "id3"}; !slices.Equal(got, want) {
+		t.Errorf("dev2 Search(a) = %v, want %v", got, want)
+	}
+	if got, want := dev1.search("b", "a"), []string{"id3"}; !slices.Equal(got, want) {
+		t.Errorf("dev1 Search(b, a) = %v, want %v", got, want)
+	}
+	if len(counters) != 2 {
+		t.Errorf("server holds %d counters, want 2", len(counters))
+	}
+	if dev1.CounterTime == 0 {
+		t.Error("CounterTime not recorded")
+	}
+	if err := dev1.Checkpoint("v1"); !errors.Is(err, ErrCheckpointCounters) {
+		t.Errorf("Checkpoint with outsourced counters error = %v, want %v", err, ErrCheckpointCounters)
+	}
+}
+
+func TestCounterKeysSeparated(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	kl, _, err := odxt.counterKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 与计数密钥的派生输入相同的关键字也得不到计数密钥
+	for _, w := range []string{"counter-label", "label", "clabel", string(domainCounter) + "label"} {
+		kw, err := odxt.KeywordKey(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(kw.T, kl) {
+			t.Errorf("KeywordKey(%q).T equals the counter label key", w)
+		}
+	}
+}
+
+// failingCounterStore 的 Get 总是失败
+type failingCounterStore struct{ MemoryCounterStore }
+
+var errCounterGet = errors.New("counter get failed")
+
+func (failingCounterStore) Get([]string) (map[string][]byte, error) { return nil, errCounterGet }
+
+func TestSearchCounterFetchError(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1")
+	odxt.Counters = failingCounterStore{NewMemoryCounterStore()}
+	if _, _, _, err := odxt.Search([]string{"a"}, ""); !errors.Is(err, errCounterGet) {
+		t.Errorf("Search error = %v, want %v", err, errCounterGet)
+	}
+}
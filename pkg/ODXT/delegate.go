@@ -0,0 +1,222 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// 多客户端委托查询：数据拥有者（Owner）为读者（Reader）签发限定关键字的授权，
+// 读者用授权中的关键字派生密钥自行生成 stoken/xtoken 并解密结果，不接触主密钥。
+// 服务器（Gatekeeper）在执行查询前验证授权签名、检查撤销列表，并检查 s-term 属于授权的关键字。
+// 更新计数仍由拥有者保存，读者每次查询时向拥有者请求其授权关键字的计数。
+//
+// 撤销只使服务器拒绝该授权，读者仍持有授权关键字的 kt_w，可以借助同一关键字的其他有效授权查询，
+// 并能计算之后写入的地址。彻底撤销需要轮换这些关键字的密钥并重新加密其条目。
+
+var (
+	ErrRevoked      = errors.New("capability revoked")
+	ErrBadSignature = errors.New("invalid capability signature")
+	ErrUnauthorized = errors.New("keyword not authorized")
+)
+
+// Capability 拥有者签发的授权，签名覆盖读者、序列号、关键字集合和令牌摘要。
+// Tokens 为每个授权关键字第一个地址的 SHA-256，服务器据此检查查询的 s-term，授权不能用于其他关键字的陷门
+type Capability struct {
+	Reader    string
+	Serial    uint64
+	Keywords  []string
+	Tokens    [][]byte
+	Signature []byte
+}
+
+// message 返回被签名的消息 reader||serial||keywords||tokens
+func (c *Capability) message() []byte {
+	msg := append([]byte(c.Reader), 0)
+	msg = binary.BigEndian.AppendUint64(msg, c.Serial)
+	for _, w := range c.Keywords {
+		msg = append(append(msg, w...), 0)
+	}
+	for _, h := range c.Tokens {
+		msg = append(msg, h...)
+	}
+	return msg
+}
+
+// allows 检查 stoken 是否为某个授权关键字的第一个地址
+func (c *Capability) allows(stoken string) bool {
+	h := sha256.Sum256([]byte(stoken))
+	return slices.ContainsFunc(c.Tokens, func(t []byte) bool { return bytes.Equal(t, h[:]) })
+}
+
+// Grant 发送给读者的授权及其关键字密钥
+type Grant struct {
+	Capability
+	Keys map[string]*KeywordKey
+}
+
+// Owner 数据拥有者，持有主密钥和更新计数
+type Owner struct {
+	*ODXT
+	priv    ed25519.PrivateKey
+	serial  uint64
+	revoked map[uint64]struct{}
+	// Gatekeeper 为服务器端的授权检查，撤销时同步更新
+	Gatekeeper *Gatekeeper
+}
+
+// Gatekeeper 服务器端的授权检查
+type Gatekeeper struct {
+	odxt    *ODXT
+	public  ed25519.PublicKey
+	revoked map[uint64]struct{}
+}
+
+// NewOwner 为 odxt 生成签名密钥并创建服务器端的 Gatekeeper
+func NewOwner(odxt *ODXT) (*Owner, error) {
+	public, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Owner{
+		ODXT:       odxt,
+		priv:       priv,
+		revoked:    make(map[uint64]struct{}),
+		Gatekeeper: &Gatekeeper{odxt: odxt, public: public, revoked: make(map[uint64]struct{})},
+	}, nil
+}
+
+// Authorize 授权读者查询 keywords
+func (o *Owner) Authorize(reader string, keywords []string) (*Grant, error) {
+	o.serial++
+	grant := &Grant{
+		Capability: Capability{Reader: reader, Serial: o.serial, Keywords: slices.Clone(keywords)},
+		Keys:       make(map[string]*KeywordKey, len(keywords)),
+	}
+	for _, w := range keywords {
+		kw, err := o.KeywordKey(w)
+		if err != nil {
+			return nil, err
+		}
+		grant.Keys[w] = kw
+		address, err := TokenAddress(kw.A, 1)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.Sum256([]byte(address))
+		grant.Tokens = append(grant.Tokens, h[:])
+	}
+	grant.Signature = ed25519.Sign(o.priv, grant.message())
+	return grant, nil
+}
+
+// Revoke 撤销序列号为 serial 的授权，并通知服务器
+func (o *Owner) Revoke(serial uint64) {
+	o.revoked[serial] = struct{}{}
+	o.Gatekeeper.revoked[serial] = struct{}{}
+}
+
+// Counts 返回授权关键字的更新计数
+func (o *Owner) Counts(c *Capability, q []string) (map[string]int, error) {
+	if _, ok := o.revoked[c.Serial]; ok {
+		return nil, ErrRevoked
+	}
+	counts := make(map[string]int, len(q))
+	for _, w := range q {
+		if !slices.Contains(c.Keywords, w) {
+			return nil, ErrUnauthorized
+		}
+		counts[w] = o.UpdateCnt[w]
+	}
+	return counts, nil
+}
+
+// Search 验证授权后执行服务器端查询，stokenList 按 j = 1, 2, ... 排列。
+// 只检查第一个地址属于授权的关键字：之后的地址为 PRF(ka_w, j)，服务器不持有 ka_w，无法逐行检查；
+// 读者也不持有未授权关键字的 ka_w，无法为其计算任何地址。
+// x-term 的 xtoken 依赖 s-term 的计数，服务器无法检查，读者只能为其持有 Fp(kx, w) 的关键字生成
+func (g *Gatekeeper) Search(c *Capability, stokenList []string, xtokenList [][]string, tableName string) (time.Duration, []utils.SEOp, error) {
+	if !ed25519.Verify(g.public, c.message(), c.Signature) {
+		return 0, nil, ErrBadSignature
+	}
+	if _, ok := g.revoked[c.Serial]; ok {
+		return 0, nil, ErrRevoked
+	}
+	if len(stokenList) > 0 && !c.allows(stokenList[0]) {
+		return 0, nil, ErrUnauthorized
+	}
+	return g.odxt.ServerSearch(stokenList, xtokenList, tableName)
+}
+
+// Reader 被授权的读者，只持有 Grant
+type Reader struct {
+	Grant    *Grant
+	p        *big.Int
+	g        *big.Int
+	encoding ValueEncoding
+}
+
+// NewReader 创建读者，p 和 g 为 odxt 的公开参数
+func (o *Owner) NewReader(grant *Grant) *Reader {
+	return &Reader{Grant: grant, p: o.p, g: o.g, encoding: o.ValueEncoding}
+}
+
+// Trapdoor 由授权中的关键字密钥和拥有者返回的计数生成陷门
+func (r *Reader) Trapdoor(q []string, counts map[string]int) (time.Duration, []string, [][]string, error) {
+	start := time.Now()
+	w1, counter := STerm(q, counts)
+	kw1, ok := r.Grant.Keys[w1]
+	if !ok {
+		return 0, nil, nil, ErrUnauthorized
+	}
+	qWithoutW1 := utils.RemoveElement(q, w1)
+	xkeys := make([]*big.Int, len(qWithoutW1))
+	for i, wi := range qWithoutW1 {
+		kwi, ok := r.Grant.Keys[wi]
+		if !ok {
+			return 0, nil, nil, ErrUnauthorized
+		}
+		xkeys[i] = kwi.X
+	}
+	stokenList, xtokenList := trapdoor(kw1, xkeys, counter, r.p, r.g)
+	return time.Since(start), stokenList, xtokenList, nil
+}
+
+// Decrypt 解密服务器返回的结果
+func (r *Reader) Decrypt(q []string, counts map[string]int, sEOpList []utils.SEOp) ([]string, error) {
+	w1, _ := STerm(q, counts)
+	kw1, ok := r.Grant.Keys[w1]
+	if !ok {
+		return nil, ErrUnauthorized
+	}
+	return decrypt(kw1, len(q), nil, r.encoding, sEOpList)
+}
+
+// Search 读者的完整查询流程：向拥有者请求计数，生成陷门，由服务器验证授权后查询，最后解密
+func (r *Reader) Search(owner *Owner, gk *Gatekeeper, q []string, tableName string) ([]string, time.Duration, time.Duration, error) {
+	counts, err := owner.Counts(&r.Grant.Capability, q)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	trapdoorTime, stokenList, xtokenList, err := r.Trapdoor(q, counts)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	serverTime, sEOpList, err := gk.Search(&r.Grant.Capability, stokenList, xtokenList, tableName)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	start := time.Now()
+	ids, err := r.Decrypt(q, counts, sEOpList)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return ids, trapdoorTime + time.Since(start), serverTime, nil
+}
@@ -0,0 +1,65 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDelegatedSearch(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1", "id2", "id3")
+	odxt.update(utils.Add, "b", "id1", "id3", "id4")
+	odxt.update(utils.Add, "c", "id3")
+
+	owner, err := NewOwner(odxt.ODXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grant, err := owner.Authorize("alice", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := owner.NewReader(grant)
+
+	ids, _, _, err := reader.Search(owner, owner.Gatekeeper, []string{"b", "a"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	if want := []string{"id1", "id3"}; !slices.Equal(ids, want) {
+		t.Errorf("Search(b, a) = %v, want %v", ids, want)
+	}
+
+	if _, _, _, err := reader.Search(owner, owner.Gatekeeper, []string{"a", "c"}, ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Search(a, c) error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	// 扩大授权范围的伪造授权无法通过服务器验证
+	forged := grant.Capability
+	forged.Keywords = []string{"a", "b", "c"}
+	_, stokenList, xtokenList, err := reader.Trapdoor([]string{"a"}, map[string]int{"a": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := owner.Gatekeeper.Search(&forged, stokenList, xtokenList, ""); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("forged capability error = %v, want %v", err, ErrBadSignature)
+	}
+
+	// 有效授权不能用于其他关键字的陷门
+	kc, err := odxt.KeywordKey("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stolen, _ := trapdoor(kc, nil, 1, odxt.p, odxt.g)
+	if _, _, err := owner.Gatekeeper.Search(&grant.Capability, stolen, [][]string{{}}, ""); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("capability for [a b] with tokens of c error = %v, want %v", err, ErrUnauthorized)
+	}
+
+	// 撤销后服务器拒绝读者自行生成的陷门
+	owner.Revoke(grant.Serial)
+	if _, _, err := owner.Gatekeeper.Search(&grant.Capability, stokenList, xtokenList, ""); !errors.Is(err, ErrRevoked) {
+		t.Errorf("revoked capability error = %v, want %v", err, ErrRevoked)
+	}
+}
@@ -0,0 +1,199 @@
+package ODXT
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// EpochStore 以固定大小的批次（epoch）写入底层 Store，隐藏更新模式：
+// 写入的条目先在客户端缓存，跨关键字打乱后按 BatchSize 分批上传，不足一批时以随机填充条目补齐。
+// 缓存达到 BatchSize 时上传一批；Interval 大于 0 时调用方需以 Interval 为周期调用 Tick（如 time.Ticker），
+// 距上次按时间上传超过 Interval 时上传一批，服务器只能看到固定时间点的等长批次，写入本身不会触发按时间的上传。
+// 按时间的上传只以 Tick 传入的时刻计时，按数量的上传不会推迟它。
+// 尚未上传的条目在查询时由客户端缓存直接返回，因此查询不会触发上传。
+// 缓存只保存在内存中，进程退出前需调用 Flush，否则尚未上传的条目会丢失。
+// Tick 可以与其他方法在不同的 goroutine 中调用。
+type EpochStore struct {
+	mu        sync.Mutex
+	Store     Store
+	BatchSize int
+	Interval  time.Duration
+	p         *big.Int
+	valSize   int // 填充条目 val 的字节数，与 ValueEncoding 一致
+	pending   []UpdatePayload
+	index     map[string]int // address -> pending 中的位置
+	lastFlush time.Time      // 上次按时间上传的时刻，只由 Tick 更新
+	// Epochs 和 Dummies 为已上传的批次数和填充条目数
+	Epochs  int
+	Dummies int
+}
+
+// NewEpochStore 创建以 batchSize 为批次大小、interval 为上传间隔（0 表示只按数量上传）的 EpochStore
+func (odxt *ODXT) NewEpochStore(store Store, batchSize int, interval time.Duration) *EpochStore {
+	batchSize = max(batchSize, 1)
+	return &EpochStore{
+		Store:     store,
+		BatchSize: batchSize,
+		Interval:  interval,
+		p:         odxt.p,
+		valSize:   odxt.ValueEncoding.valueSize(),
+		index:     make(map[string]int),
+		lastFlush: time.Now(),
+	}
+}
+
+func (e *EpochStore) Write(uploadList []UpdatePayload) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, payload := range uploadList {
+		e.index[payload.Address] = len(e.pending)
+		e.pending = append(e.pending, payload)
+	}
+	for len(e.pending) >= e.BatchSize {
+		if err := e.flushBatch(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tick 在时刻 now 距上次上传不少于 Interval 时上传一批，缓存为空时上传全部为填充条目的一批。
+// now 应取自 time.Ticker 的触发时间，使按时间的上传间隔恰为 Interval
+func (e *EpochStore) Tick(now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Interval > 0 && now.Sub(e.lastFlush) >= e.Interval {
+		if err := e.flushBatch(); err != nil {
+			return err
+		}
+		e.lastFlush = now
+	}
+	return nil
+}
+
+// Flush 上传全部缓存，最后一批以填充条目补齐
+func (e *EpochStore) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for len(e.pending) > 0 {
+		if err := e.flushBatch(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *EpochStore) Search(address []string) ([]SearchPayload, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	result := make([]SearchPayload, len(address))
+	remote := make([]string, 0, len(address))
+	pos := make([]int, 0, len(address))
+	for i, addr := range address {
+		if j, ok := e.index[addr]; ok {
+			result[i] = SearchPayload{Value: e.pending[j].Val, Alpha: e.pending[j].Alpha}
+			continue
+		}
+		remote, pos = append(remote, addr), append(pos, i)
+	}
+	if len(remote) > 0 {
+		payloads, err := e.Store.Search(remote)
+		if err != nil {
+			return nil, err
+		}
+		for k, payload := range payloads {
+			result[pos[k]] = payload
+		}
+	}
+	return result, nil
+}
+
+func (e *EpochStore) Delete(address []string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	remote := make([]string, 0, len(address))
+	for _, addr := range address {
+		if j, ok := e.index[addr]; ok {
+			e.remove(j)
+			continue
+		}
+		remote = append(remote, addr)
+	}
+	return e.Store.Delete(remote)
+}
+
+// remove 从缓存中删除第 j 个条目
+func (e *EpochStore) remove(j int) {
+	delete(e.index, e.pending[j].Address)
+	last := len(e.pending) - 1
+	if j != last {
+		e.pending[j] = e.pending[last]
+		e.index[e.pending[j].Address] = j
+	}
+	e.pending = e.pending[:last]
+}
+
+// flushBatch 打乱缓存，取出至多 BatchSize 个条目并补齐后上传
+func (e *EpochStore) flushBatch() error {
+	for i := len(e.pending) - 1; i > 0; i-- {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		e.pending[i], e.pending[k.Int64()] = e.pending[k.Int64()], e.pending[i]
+	}
+
+	n := min(e.BatchSize, len(e.pending))
+	batch := make([]UpdatePayload, 0, e.BatchSize)
+	batch = append(batch, e.pending[:n]...)
+	for len(batch) < e.BatchSize {
+		dummy, err := e.dummy()
+		if err != nil {
+			return err
+		}
+		batch = append(batch, dummy)
+		e.Dummies++
+	}
+	// 打乱填充条目的位置
+	for i := len(batch) - 1; i > 0; i-- {
+		k, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		batch[i], batch[k.Int64()] = batch[k.Int64()], batch[i]
+	}
+	if err := e.Store.Write(batch); err != nil {
+		return err
+	}
+
+	e.pending = append(e.pending[:0], e.pending[n:]...)
+	e.index = make(map[string]int, len(e.pending))
+	for j, payload := range e.pending {
+		e.index[payload.Address] = j
+	}
+	e.Epochs++
+	return nil
+}
+
+// dummy 生成与任何关键字无关的随机条目，地址不会被任何 stoken 查询到
+func (e *EpochStore) dummy() (UpdatePayload, error) {
+	address, val := make([]byte, 32), make([]byte, e.valSize)
+	if _, err := rand.Read(address); err != nil {
+		return UpdatePayload{}, err
+	}
+	if _, err := rand.Read(val); err != nil {
+		return UpdatePayload{}, err
+	}
+	alpha, err := rand.Int(rand.Reader, new(big.Int).Sub(e.p, big.NewInt(1)))
+	if err != nil {
+		return UpdatePayload{}, err
+	}
+	return UpdatePayload{
+		Address: base64.StdEncoding.EncodeToString(address),
+		Val:     base64.StdEncoding.EncodeToString(val),
+		Alpha:   base64.StdEncoding.EncodeToString(alpha.Bytes()),
+	}, nil
+}
@@ -0,0 +1,71 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEpochStore(t *testing.T) {
+	server := NewMemoryStore()
+	odxt := newTestODXT(t, server)
+	epochs := odxt.NewEpochStore(server, 4, 0)
+	odxt.Store = epochs
+
+	odxt.update(utils.Add, "a", "id1", "id2", "id3")
+	odxt.update(utils.Add, "b", "id1", "id3")
+	// 5 个条目中 4 个已作为一批上传，剩余 1 个在客户端缓存
+	if len(server) != 4 || epochs.Epochs != 1 || len(epochs.pending) != 1 {
+		t.Errorf("server %d, epochs %d, pending %d; want 4, 1, 1", len(server), epochs.Epochs, len(epochs.pending))
+	}
+	if got, want := odxt.search("b", "a"), []string{"id1", "id3"}; !slices.Equal(got, want) {
+		t.Errorf("Search(b, a) before flush = %v, want %v", got, want)
+	}
+
+	if err := epochs.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(server) != 8 || epochs.Dummies != 3 || len(epochs.pending) != 0 {
+		t.Errorf("server %d, dummies %d, pending %d; want 8, 3, 0", len(server), epochs.Dummies, len(epochs.pending))
+	}
+	if got, want := odxt.search("a", "b"), []string{"id1", "id3"}; !slices.Equal(got, want) {
+		t.Errorf("Search(a, b) after flush = %v, want %v", got, want)
+	}
+}
+
+func TestEpochTick(t *testing.T) {
+	server := NewMemoryStore()
+	odxt := newTestODXT(t, server)
+	epochs := odxt.NewEpochStore(server, 4, time.Minute)
+	odxt.Store = epochs
+	start := epochs.lastFlush
+
+	// 写入不会触发按时间的上传
+	odxt.update(utils.Add, "a", "id1")
+	if err := epochs.Tick(start.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if len(server) != 0 || len(epochs.pending) != 1 {
+		t.Errorf("server %d, pending %d before interval; want 0, 1", len(server), len(epochs.pending))
+	}
+
+	// 每个间隔上传一批，缓存为空时上传全部为填充条目的一批
+	for i := 1; i <= 2; i++ {
+		if err := epochs.Tick(start.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(server) != 8 || epochs.Epochs != 2 || epochs.Dummies != 7 {
+		t.Errorf("server %d, epochs %d, dummies %d; want 8, 2, 7", len(server), epochs.Epochs, epochs.Dummies)
+	}
+
+	// 按数量的上传不推迟按时间的上传
+	odxt.update(utils.Add, "b", "id1", "id2", "id3", "id4")
+	if err := epochs.Tick(start.Add(3 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if epochs.Epochs != 4 {
+		t.Errorf("epochs %d after count and time uploads, want 4", epochs.Epochs)
+	}
+}
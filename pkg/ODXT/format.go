@@ -0,0 +1,48 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// FormatVersion 加密索引及其客户端状态文件（XSet、UpdateCnt）的格式版本，条目的密钥派生方式改变时递增：
+//
+//  1. 基线：address = PRF(kt, w||wc||0)，val = PRF(kt, w||wc||1) xor (id||op)，alpha 使用 Fp(kz, w||wc)
+//  2. 关键字派生密钥（委托查询）：kt_w = PRF(kt, 'w'||w)，ka_w = PRF(kt_w, 0)，kz_w = PRF(kz, w)，
+//     wc 编码为 8 字节：address = PRF(ka_w, wc)，val 的掩码 PRF(kt_w, 1||wc)，AEAD 密钥 PRF(kt_w, 2||wc)，
+//     alpha 使用 Fp(kz_w, wc)；外包计数的密钥为 PRF(kt, 'c'||用途)
+//
+// 不同版本生成的 MySQL 表和状态文件互不兼容，需要用 c 阶段重新生成。
+const FormatVersion = 2
+
+// ErrFormatVersion 状态文件由不兼容的格式版本生成
+var ErrFormatVersion = errors.New("encrypted index uses an incompatible key derivation, regenerate it with phase c")
+
+type formatInfo struct {
+	Version int `json:"version"`
+}
+
+// formatPath 返回计数文件 <prefix>_UpdateCnt.json 对应的格式文件 <prefix>_Format.json
+func formatPath(updateCntPath string) string {
+	return strings.TrimSuffix(updateCntPath, "_UpdateCnt.json") + "_Format.json"
+}
+
+// saveFormat 在计数文件旁记录当前的格式版本
+func saveFormat(updateCntPath string) error {
+	return utils.SaveJSONToFile(formatInfo{Version: FormatVersion}, formatPath(updateCntPath))
+}
+
+// checkFormat 检查计数文件的格式版本，没有格式文件的视为版本 1
+func checkFormat(updateCntPath string) error {
+	info := formatInfo{Version: 1}
+	if err := utils.LoadJSONFromFile(&info, formatPath(updateCntPath)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if info.Version != FormatVersion {
+		return fmt.Errorf("%w: %s has version %d, want %d", ErrFormatVersion, updateCntPath, info.Version, FormatVersion)
+	}
+	return nil
+}
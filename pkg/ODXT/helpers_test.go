@@ -0,0 +1,51 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"slices"
+	"testing"
+)
+
+// testODXT 各测试共用的 ODXT 客户端，使用固定主密钥，加密索引保存在内存中
+type testODXT struct {
+	*ODXT
+	t *testing.T
+}
+
+func newTestODXT(t *testing.T, store Store) *testODXT {
+	var keys [4][]byte
+	for i := range keys {
+		keys[i] = []byte("0123456789abcdef0123456789abcdef")
+	}
+	odxt := &ODXT{}
+	odxt.Init(keys, store)
+	return &testODXT{odxt, t}
+}
+
+// update 加密 (w, ids) 的更新并写入 Store
+func (o *testODXT) update(op utils.Operation, w string, ids ...string) []UpdatePayload {
+	o.t.Helper()
+	_, payloads, err := o.Encrypt(w, ids, int(op))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	if err := o.Store.Write(payloads); err != nil {
+		o.t.Fatal(err)
+	}
+	return payloads
+}
+
+// search 查询 q 并返回排序后的结果
+func (o *testODXT) search(q ...string) []string {
+	o.t.Helper()
+	_, _, sEOpList, err := o.Search(q, "")
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	ids, err := o.Decrypt(q, sEOpList)
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	slices.Sort(ids)
+	return ids
+}
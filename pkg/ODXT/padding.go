@@ -0,0 +1,139 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// PadMode s-term 列表的填充方式
+type PadMode int
+
+const (
+	NoPadding     PadMode = iota
+	PadPowerOfTwo         // 填充到不小于计数的 2 的幂
+	PadStep               // 填充到 PadStep 的整数倍
+	PadLaplace            // 每次更新添加截断平移 Laplace 分布数量的填充条目，见 laplaceDummies
+)
+
+// DefaultDelta PadLaplace 模式下 Delta 未设置时使用的 δ
+const DefaultDelta = 1e-6
+
+func (m PadMode) String() string {
+	switch m {
+	case PadPowerOfTwo:
+		return "pow2"
+	case PadStep:
+		return "step"
+	case PadLaplace:
+		return "laplace"
+	default:
+		return "none"
+	}
+}
+
+// dummyCount 计数为 n 的关键字本次更新需要的填充条目数
+func (odxt *ODXT) dummyCount(n int) (int, error) {
+	if odxt.Padding == PadLaplace {
+		return odxt.laplaceDummies()
+	}
+	return odxt.paddedVolume(n) - n, nil
+}
+
+// paddedVolume 计数 n 按桶填充后的长度
+func (odxt *ODXT) paddedVolume(n int) int {
+	switch odxt.Padding {
+	case PadPowerOfTwo:
+		v := 1
+		for v < n {
+			v <<= 1
+		}
+		return v
+	case PadStep:
+		if odxt.PadStep <= 0 {
+			return n
+		}
+		return (n + odxt.PadStep - 1) / odxt.PadStep * odxt.PadStep
+	default:
+		return n
+	}
+}
+
+// dummyEntry 生成关键字的一条填充条目：地址与真实条目格式相同，值中的 op 为 utils.Dummy，
+// alpha 为随机数，服务器计算出的 xtag 不在 XSet 中，也不向 XSet 添加任何 xtag
+func (odxt *ODXT) dummyEntry(keyword string, kw *KeywordKey) (UpdatePayload, error) {
+	odxt.UpdateCnt[keyword]++
+	address, err := TokenAddress(kw.A, odxt.UpdateCnt[keyword])
+	if err != nil {
+		return UpdatePayload{}, err
+	}
+	k, err := odxt.ValueEncoding.entryKey(kw.T, odxt.UpdateCnt[keyword])
+	if err != nil {
+		return UpdatePayload{}, err
+	}
+	val, err := odxt.ValueEncoding.encode(k, []byte(address), nil, int(utils.Dummy))
+	if err != nil {
+		return UpdatePayload{}, err
+	}
+	alpha, err := rand.Int(rand.Reader, new(big.Int).Sub(odxt.p, big.NewInt(1)))
+	if err != nil {
+		return UpdatePayload{}, err
+	}
+
+	return UpdatePayload{
+		Address: address,
+		Val:     base64.StdEncoding.EncodeToString(val),
+		Alpha:   base64.StdEncoding.EncodeToString(alpha.Bytes()),
+	}, nil
+}
+
+// laplaceShift 截断平移 Laplace 的平移量 μ = b·ln(1/(2δ))，b = 1/ε，
+// 使负噪声被截断的概率不超过 δ
+func (odxt *ODXT) laplaceShift() float64 {
+	delta := odxt.Delta
+	if delta <= 0 {
+		delta = DefaultDelta
+	}
+	return math.Log(1/(2*delta)) / odxt.Epsilon
+}
+
+// laplaceDummies 采样 max(0, ⌈μ + Lap(1/ε)⌉) 个填充条目。
+// 每次更新的条目数的敏感度为 1，服务器观察到的每个关键字的更新数量满足 (ε, δ)-差分隐私
+func (odxt *ODXT) laplaceDummies() (int, error) {
+	if odxt.Epsilon <= 0 {
+		return 0, errors.New("epsilon must be positive")
+	}
+	// u 在 (-0.5, 0.5) 内均匀分布
+	r, err := rand.Int(rand.Reader, big.NewInt(1<<53))
+	if err != nil {
+		return 0, err
+	}
+	u := (float64(r.Int64())+0.5)/(1<<53) - 0.5
+	b := 1 / odxt.Epsilon
+	noise := -b * math.Copysign(math.Log(1-2*math.Abs(u)), u)
+	return max(0, int(math.Ceil(odxt.laplaceShift()+noise))), nil
+}
+
+// VolumeBound 返回 PadLaplace 模式下的泄露界 (ε, δ) 以及区间宽度 2μ：
+// 服务器观察到更新数量 v 时，真实数量落在 [v-2μ, v] 之外的概率不超过 δ
+func (odxt *ODXT) VolumeBound() (epsilon, delta, width float64) {
+	delta = odxt.Delta
+	if delta <= 0 {
+		delta = DefaultDelta
+	}
+	return odxt.Epsilon, delta, 2 * odxt.laplaceShift()
+}
+
+// VolumeRange 服务器对一次写入 n 个 id 的关键字可能观察到的 s-term 列表长度范围，
+// PadLaplace 模式下以 1-δ 的概率成立
+func (odxt *ODXT) VolumeRange(n int) (lo, hi int) {
+	if odxt.Padding == PadLaplace {
+		_, _, width := odxt.VolumeBound()
+		return n, n + int(math.Ceil(width))
+	}
+	v := odxt.paddedVolume(n)
+	return v, v
+}
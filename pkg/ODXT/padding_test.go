@@ -0,0 +1,70 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"slices"
+	"testing"
+)
+
+func TestPadding(t *testing.T) {
+	for _, tc := range []struct {
+		mode  PadMode
+		step  int
+		wantA int
+		wantB int
+	}{
+		{PadPowerOfTwo, 0, 4, 8},
+		{PadStep, 3, 3, 6},
+	} {
+		odxt := newTestODXT(t, NewMemoryStore())
+		odxt.Padding, odxt.PadStep = tc.mode, tc.step
+		odxt.update(utils.Add, "a", "id1", "id2", "id3")
+		odxt.update(utils.Add, "b", "id1", "id3")
+		odxt.update(utils.Add, "b", "id4", "id5", "id6")
+
+		if odxt.UpdateCnt["a"] != tc.wantA || odxt.UpdateCnt["b"] != tc.wantB {
+			t.Errorf("%v: padded volumes %d, %d, want %d, %d", tc.mode, odxt.UpdateCnt["a"], odxt.UpdateCnt["b"], tc.wantA, tc.wantB)
+		}
+		for _, q := range [][]string{{"a", "b"}, {"b", "a"}, {"b"}} {
+			ids := odxt.search(q...)
+			want := []string{"id1", "id3"}
+			if len(q) == 1 {
+				want = []string{"id1", "id3", "id4", "id5", "id6"}
+			}
+			if !slices.Equal(ids, want) {
+				t.Errorf("%v: Search(%v) = %v, want %v", tc.mode, q, ids, want)
+			}
+		}
+	}
+}
+
+func TestLaplacePadding(t *testing.T) {
+	var odxt ODXT
+	odxt.Padding, odxt.Epsilon = PadLaplace, 1
+	if _, err := odxt.laplaceDummies(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 平均填充数约为 μ = ln(1/(2δ))/ε
+	const n = 2000
+	total := 0
+	for i := 0; i < n; i++ {
+		d, err := odxt.laplaceDummies()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d < 0 {
+			t.Fatalf("negative dummy count %d", d)
+		}
+		total += d
+	}
+	_, _, width := odxt.VolumeBound()
+	if mean := float64(total) / n; mean < width/2-1 || mean > width/2+2 {
+		t.Errorf("mean dummies %.2f, want about %.2f", mean, width/2)
+	}
+
+	odxt.Epsilon = 0
+	if _, err := odxt.laplaceDummies(); err == nil {
+		t.Error("laplaceDummies with epsilon 0 succeeded")
+	}
+}
@@ -0,0 +1,82 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"math/big"
+)
+
+// ErrTooManyConjuncts 填充 xtoken 时查询的关键字数超过 MaxConjuncts
+var ErrTooManyConjuncts = errors.New("query has more keywords than MaxConjuncts")
+
+// hideQuerySize 是否填充并打乱 xtoken。HXT 模式下第二轮要求所有位置都为 1，填充的 xtoken 会使匹配失败，因此不生效
+func (odxt *ODXT) hideQuerySize() bool {
+	return odxt.MaxConjuncts > 0 && odxt.HXT == nil
+}
+
+// padXtokens 将每行 xtoken 以随机群元素 g^r 填充到 MaxConjuncts-1 个，并用 Fisher-Yates 打乱每一行，
+// 服务器看到的每行 xtoken 数量相同、顺序与查询关键字无关，无法区分不同长度的查询。
+// 真实 xtoken 打乱后的位置记录在 xtokenPos 中，填充的 xtoken 因 Bloom 过滤器误判命中时不会被计入
+// 调用方需保证查询的关键字数不超过 MaxConjuncts
+func (odxt *ODXT) padXtokens(xtokenList [][]string) error {
+	pMinus1 := new(big.Int).Sub(odxt.p, big.NewInt(1))
+	odxt.xtokenPos = make([][]int, len(xtokenList))
+	for j, row := range xtokenList {
+		// perm[i] 为第 i 个位置上 xtoken 打乱前的下标，下标小于 nReal 的为真实 xtoken
+		nReal := len(row)
+		perm := make([]int, 0, odxt.MaxConjuncts-1)
+		for len(row) < odxt.MaxConjuncts-1 {
+			r, err := rand.Int(rand.Reader, pMinus1)
+			if err != nil {
+				return err
+			}
+			row = append(row, base64.StdEncoding.EncodeToString(new(big.Int).Exp(odxt.g, r, odxt.p).Bytes()))
+		}
+		for i := range row {
+			perm = append(perm, i)
+		}
+		for i := len(row) - 1; i > 0; i-- {
+			k, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return err
+			}
+			row[i], row[k.Int64()] = row[k.Int64()], row[i]
+			perm[i], perm[k.Int64()] = perm[k.Int64()], perm[i]
+		}
+		xtokenList[j] = row
+		for i, orig := range perm {
+			if orig < nReal {
+				odxt.xtokenPos[j] = append(odxt.xtokenPos[j], i)
+			}
+		}
+	}
+	return nil
+}
+
+// realXtokens 返回解密时使用的真实 xtoken 位置，未填充时为 nil
+func (odxt *ODXT) realXtokens() [][]int {
+	if !odxt.hideQuerySize() {
+		return nil
+	}
+	return odxt.xtokenPos
+}
+
+// matches 返回条目匹配的查询关键字数（含 s-term）。pos 为 nil 时即服务器给出的 Cnt，
+// 否则只统计第 J 行中真实 xtoken 所在位置的命中
+func matches(sEOp utils.SEOp, pos [][]int) int {
+	if pos == nil {
+		return sEOp.Cnt
+	}
+	if sEOp.J < 1 || sEOp.J > len(pos) {
+		return 0
+	}
+	cnt := 1
+	for _, i := range pos[sEOp.J-1] {
+		if i < len(sEOp.Hits) && sEOp.Hits[i] {
+			cnt++
+		}
+	}
+	return cnt
+}
@@ -0,0 +1,85 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPaddedXtokens(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1", "id2", "id3")
+	odxt.update(utils.Add, "b", "id1", "id3", "id4", "id5")
+	odxt.update(utils.Add, "c", "id3", "id4", "id5", "id6")
+	odxt.update(utils.Add, "d", "id1", "id3", "id5", "id6", "id7")
+
+	for _, q := range [][]string{{"a"}, {"b", "a"}, {"a", "b", "d"}, {"d", "c", "b", "a"}} {
+		odxt.MaxConjuncts = 0
+		want := odxt.search(q...)
+
+		odxt.MaxConjuncts = 6
+		_, _, xtokenList, err := odxt.Trapdoor(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, row := range xtokenList {
+			if len(row) != 5 {
+				t.Fatalf("Trapdoor(%v) row has %d xtokens, want 5", q, len(row))
+			}
+		}
+		if got := odxt.search(q...); !slices.Equal(got, want) {
+			t.Errorf("Search(%v) with padding = %v, want %v", q, got, want)
+		}
+	}
+}
+
+func TestPaddedXtokenFalsePositive(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1", "id2")
+	odxt.update(utils.Add, "b", "id1", "id3", "id4")
+	odxt.MaxConjuncts = 4
+
+	q := []string{"a", "b"}
+	_, stokenList, xtokenList, err := odxt.Trapdoor(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sEOpList, err := odxt.ServerSearch(stokenList, xtokenList, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// id2 不含 b，模拟填充的 xtoken 因 Bloom 过滤器误判而命中
+	row := &sEOpList[1]
+	for i := range row.Hits {
+		if !slices.Contains(odxt.xtokenPos[1], i) {
+			row.Hits[i] = true
+			row.Cnt++
+			break
+		}
+	}
+	ids, err := odxt.Decrypt(q, sEOpList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id1"}; !slices.Equal(ids, want) {
+		t.Errorf("Decrypt(%v) = %v, want %v", q, ids, want)
+	}
+}
+
+func TestTooManyConjuncts(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	odxt.update(utils.Add, "a", "id1", "id2")
+	odxt.update(utils.Add, "b", "id1", "id3")
+	odxt.update(utils.Add, "c", "id1")
+	odxt.MaxConjuncts = 2
+
+	// 先执行一次合法查询，留下填充位置
+	odxt.search("a", "b")
+	if _, _, _, err := odxt.Search([]string{"a", "b", "c"}, ""); !errors.Is(err, ErrTooManyConjuncts) {
+		t.Errorf("Search with 3 keywords error = %v, want %v", err, ErrTooManyConjuncts)
+	}
+	if odxt.xtokenPos != nil {
+		t.Error("rejected query kept the previous query's xtoken positions")
+	}
+}
@@ -0,0 +1,340 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+)
+
+// 可验证查询：更新时客户端维护两类认证数据
+//  1. 每个关键字的 MAC 链 c_j = HMAC(kv, c_{j-1}||address_j||val_j)，客户端只保存链尾，
+//     用于检查服务器返回的 s-term 列表完整且未被篡改；
+//  2. XSet（Bloom 过滤器）上的 Merkle 树，客户端只保存根。查询时服务器为其计算的 xtag 所在的叶子
+//     附上认证路径，客户端解密 s-term 条目后自行计算 xtag，据此检查服务器给出的匹配数。
+
+var (
+	// ErrIncomplete 服务器返回的 s-term 列表缺少、多出或篡改了条目
+	ErrIncomplete = errors.New("s-term list is incomplete or has been modified")
+	// ErrBadProof 服务器给出的 XSet 叶子与客户端保存的根不一致
+	ErrBadProof = errors.New("XSet proof does not match the root")
+	// ErrDishonest 服务器给出的匹配数与 XSet 不一致
+	ErrDishonest = errors.New("server returned a wrong match count")
+	// ErrVerifyHXT HXT 模式下服务器不计算匹配数
+	ErrVerifyHXT = errors.New("verification is not supported in HXT mode")
+)
+
+// leafWords 每个 Merkle 叶子包含的 Bloom 过滤器 64 位字数
+const leafWords = 64
+
+// AuthXSet 服务器端 XSet 上的 Merkle 树
+type AuthXSet struct {
+	filter *bloom.BloomFilter
+	size   int      // 叶子数，为 2 的幂
+	nodes  [][]byte // nodes[1] 为根，nodes[size+i] 为第 i 个叶子
+}
+
+func newAuthXSet(filter *bloom.BloomFilter) *AuthXSet {
+	leaves := (len(filter.BitSet().Bytes()) + leafWords - 1) / leafWords
+	size := 1
+	for size < leaves {
+		size <<= 1
+	}
+	a := &AuthXSet{filter: filter, size: size, nodes: make([][]byte, 2*size)}
+	for i := 0; i < size; i++ {
+		a.nodes[size+i] = leafHash(i, a.block(i))
+	}
+	for i := size - 1; i >= 1; i-- {
+		a.nodes[i] = nodeHash(a.nodes[2*i], a.nodes[2*i+1])
+	}
+	return a
+}
+
+// block 返回叶子 i 包含的字，超出过滤器的部分为 0
+func (a *AuthXSet) block(i int) []uint64 {
+	words := a.filter.BitSet().Bytes()
+	block := make([]uint64, leafWords)
+	if i*leafWords < len(words) {
+		copy(block, words[i*leafWords:])
+	}
+	return block
+}
+
+// open 返回叶子的内容及从叶子到根的兄弟节点
+func (a *AuthXSet) open(leaf int) ([]uint64, [][]byte) {
+	path := make([][]byte, 0)
+	for i := a.size + leaf; i > 1; i >>= 1 {
+		path = append(path, a.nodes[i^1])
+	}
+	return a.block(leaf), path
+}
+
+// set 设置第 pos 位并更新所在叶子到根的路径
+func (a *AuthXSet) set(pos uint64) {
+	a.filter.BitSet().Set(uint(pos))
+	leaf := int(pos / (64 * leafWords))
+	i := a.size + leaf
+	a.nodes[i] = leafHash(leaf, a.block(leaf))
+	for i >>= 1; i >= 1; i >>= 1 {
+		a.nodes[i] = nodeHash(a.nodes[2*i], a.nodes[2*i+1])
+	}
+}
+
+func leafHash(leaf int, block []uint64) []byte {
+	buf := make([]byte, 1+8+8*len(block))
+	binary.BigEndian.PutUint64(buf[1:], uint64(leaf))
+	for i, w := range block {
+		binary.BigEndian.PutUint64(buf[9+8*i:], w)
+	}
+	h := sha256.Sum256(buf)
+	return h[:]
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// rootFrom 由叶子内容和认证路径计算根
+func rootFrom(leaf int, block []uint64, path [][]byte) []byte {
+	h := leafHash(leaf, block)
+	for _, sibling := range path {
+		if leaf&1 == 0 {
+			h = nodeHash(h, sibling)
+		} else {
+			h = nodeHash(sibling, h)
+		}
+		leaf >>= 1
+	}
+	return h
+}
+
+// XSetProof 一次查询中服务器访问的叶子及其认证路径
+type XSetProof struct {
+	Blocks map[int][]uint64
+	Paths  map[int][][]byte
+}
+
+// Size 证明的字节数
+func (p *XSetProof) Size() int {
+	size := 0
+	for leaf, block := range p.Blocks {
+		size += 8 * len(block)
+		for _, sibling := range p.Paths[leaf] {
+			size += len(sibling)
+		}
+	}
+	return size
+}
+
+// Verifier 客户端保存的认证数据
+type Verifier struct {
+	key []byte
+	// Root 为 XSet 上 Merkle 树的根
+	Root []byte
+	// Chains 为每个关键字 MAC 链的链尾
+	Chains map[string][]byte
+	// Proof 为服务器对最近一次查询给出的证明
+	Proof *XSetProof
+	xset  *AuthXSet
+	// leaves 为服务器本次查询访问的叶子
+	leaves map[int]struct{}
+}
+
+// EnableVerification 建立 XSet 上的 Merkle 树，并读取 tableName 中已有的条目计算每个关键字的 MAC 链。
+// 启用时信任服务器的当前状态，之后的更新和查询均可验证。
+func (odxt *ODXT) EnableVerification(tableName string) error {
+	if odxt.HXT != nil {
+		return ErrVerifyHXT
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	v := &Verifier{key: key, Chains: make(map[string][]byte), xset: newAuthXSet(odxt.XSet)}
+	v.Root = v.xset.nodes[1]
+
+	for keyword, n := range odxt.UpdateCnt {
+		kw, err := odxt.KeywordKey(keyword)
+		if err != nil {
+			return err
+		}
+		addresses := make([]string, n)
+		for j := range addresses {
+			if addresses[j], err = TokenAddress(kw.A, j+1); err != nil {
+				return err
+			}
+		}
+		payloads, err := odxt.store(tableName).Search(addresses)
+		if err != nil {
+			return err
+		}
+		for j, payload := range payloads {
+			v.chain(keyword, addresses[j], payload.Value)
+		}
+	}
+	odxt.Verifier = v
+	return nil
+}
+
+// chain 将条目加入关键字的 MAC 链
+func (v *Verifier) chain(keyword, address, val string) {
+	v.Chains[keyword] = v.next(v.Chains[keyword], address, val)
+}
+
+func (v *Verifier) next(c []byte, address, val string) []byte {
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write(c)
+	mac.Write([]byte(address))
+	mac.Write([]byte(val))
+	return mac.Sum(nil)
+}
+
+// positions xtag 在 Bloom 过滤器中的位置，与 BloomFilter.Add 一致
+func (v *Verifier) positions(xtag []byte) []uint64 {
+	filter := v.xset.filter
+	locs := bloom.Locations(xtag, filter.K())
+	for i := range locs {
+		locs[i] %= uint64(filter.Cap())
+	}
+	return locs
+}
+
+// addXtag 将 xtag 加入 XSet：对每个位置取回叶子和认证路径，验证后在本地置位并计算新的根
+func (v *Verifier) addXtag(xtag []byte) error {
+	for _, pos := range v.positions(xtag) {
+		leaf := int(pos / (64 * leafWords))
+		block, path := v.xset.open(leaf)
+		if !bytes.Equal(rootFrom(leaf, block, path), v.Root) {
+			return ErrBadProof
+		}
+		block[pos/64%leafWords] |= 1 << (pos % 64)
+		v.Root = rootFrom(leaf, block, path)
+		v.xset.set(pos)
+	}
+	return nil
+}
+
+// collect 服务器记录查询中计算的 xtag 所在的叶子
+func (v *Verifier) collect(xtag []byte) {
+	for _, pos := range v.positions(xtag) {
+		v.leaves[int(pos/(64*leafWords))] = struct{}{}
+	}
+}
+
+// prove 服务器为本次查询访问的叶子生成证明
+func (v *Verifier) prove() {
+	proof := &XSetProof{Blocks: make(map[int][]uint64, len(v.leaves)), Paths: make(map[int][][]byte, len(v.leaves))}
+	for leaf := range v.leaves {
+		proof.Blocks[leaf], proof.Paths[leaf] = v.xset.open(leaf)
+	}
+	v.Proof, v.leaves = proof, nil
+}
+
+// test 用证明中的叶子检查 xtag 是否在 XSet 中，verified 缓存已验证的叶子
+func (v *Verifier) test(xtag []byte, verified map[int][]uint64) (bool, error) {
+	if v.Proof == nil {
+		return false, ErrBadProof
+	}
+	member := true
+	for _, pos := range v.positions(xtag) {
+		leaf := int(pos / (64 * leafWords))
+		block, ok := verified[leaf]
+		if !ok {
+			block, ok = v.Proof.Blocks[leaf]
+			if !ok || len(block) != leafWords || !bytes.Equal(rootFrom(leaf, block, v.Proof.Paths[leaf]), v.Root) {
+				return false, ErrBadProof
+			}
+			verified[leaf] = block
+		}
+		if block[pos/64%leafWords]&(1<<(pos%64)) == 0 {
+			member = false
+		}
+	}
+	return member, nil
+}
+
+// Verify 在 Decrypt 之后验证查询 q 的结果：检查 s-term 列表与 MAC 链一致，
+// 并对每个非填充条目在客户端计算 x-term 的 xtag，用服务器的证明检查其给出的匹配数。
+// MaxConjuncts 填充时只检查真实 xtoken 位置上的命中，见 matches。
+func (odxt *ODXT) Verify(q []string, sEOpList []utils.SEOp) (time.Duration, error) {
+	start := time.Now()
+	v := odxt.Verifier
+	if odxt.HXT != nil {
+		return 0, ErrVerifyHXT
+	}
+	w1, n := STerm(q, odxt.UpdateCnt)
+	if len(sEOpList) != n {
+		return time.Since(start), ErrIncomplete
+	}
+	kw1, err := odxt.KeywordKey(w1)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	// 检查 s-term 列表
+	var c []byte
+	for j, sEOp := range sEOpList {
+		if sEOp.J != j+1 {
+			return time.Since(start), ErrIncomplete
+		}
+		address, err := TokenAddress(kw1.A, j+1)
+		if err != nil {
+			return time.Since(start), err
+		}
+		c = v.next(c, address, sEOp.Sval)
+	}
+	if !hmac.Equal(c, v.Chains[w1]) {
+		return time.Since(start), ErrIncomplete
+	}
+
+	// 检查匹配数
+	xkeys := make([]*big.Int, 0, len(q)-1)
+	for _, w := range utils.RemoveElement(q, w1) {
+		kw, err := odxt.KeywordKey(w)
+		if err != nil {
+			return time.Since(start), err
+		}
+		xkeys = append(xkeys, kw.X)
+	}
+	verified := make(map[int][]uint64)
+	for _, sEOp := range sEOpList {
+		id, op, err := decryptEntry(kw1, sEOp.J, sEOp.Sval, odxt.ValueEncoding)
+		if err != nil {
+			return time.Since(start), err
+		}
+		if op == utils.Dummy {
+			continue
+		}
+		// Encrypt 拒绝会被截断的 id（见 checkID），解密得到的 id 与计算 alpha 时的输入相同
+		alpha1, err := utils.PrfFp(odxt.Keys[2], append([]byte(id), byte(op)), odxt.p, odxt.g)
+		if err != nil {
+			return time.Since(start), err
+		}
+		cnt := 1
+		for _, x := range xkeys {
+			xtag := new(big.Int).Exp(odxt.g, new(big.Int).Mul(x, alpha1), odxt.p)
+			member, err := v.test(xtag.Bytes(), verified)
+			if err != nil {
+				return time.Since(start), err
+			}
+			if member {
+				cnt++
+			}
+		}
+		if cnt != matches(sEOp, odxt.realXtokens()) {
+			return time.Since(start), ErrDishonest
+		}
+	}
+	return time.Since(start), nil
+}
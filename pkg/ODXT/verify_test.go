@@ -0,0 +1,105 @@
+package ODXT
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	search := func(q ...string) []utils.SEOp {
+		_, _, sEOpList, err := odxt.Search(q, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sEOpList
+	}
+
+	// 启用前写入的条目在启用时计入 MAC 链
+	odxt.update(utils.Add, "a", "id1", "id2", "id3")
+	if err := odxt.EnableVerification(""); err != nil {
+		t.Fatal(err)
+	}
+	odxt.update(utils.Add, "b", "id1", "id3")
+	odxt.update(utils.Add, "a", "id4")
+	odxt.update(utils.Add, "c", "id3", "id4")
+	if v := odxt.Verifier; !bytes.Equal(v.Root, v.xset.nodes[1]) {
+		t.Fatal("client root differs from server root")
+	}
+
+	for _, q := range [][]string{{"a"}, {"b", "a"}, {"a", "c"}, {"b", "c", "a"}} {
+		sEOpList := search(q...)
+		if _, err := odxt.Verify(q, sEOpList); err != nil {
+			t.Errorf("Verify(%v) = %v", q, err)
+		}
+	}
+	if ids, want := odxt.search("a", "c"), []string{"id3", "id4"}; !slices.Equal(ids, want) {
+		t.Errorf("Search(a, c) = %v, want %v", ids, want)
+	}
+
+	q := []string{"a", "b"}
+	tests := []struct {
+		name   string
+		tamper func(sEOpList []utils.SEOp) []utils.SEOp
+		want   error
+	}{
+		{"drop entry", func(l []utils.SEOp) []utils.SEOp { return l[:len(l)-1] }, ErrIncomplete},
+		{"modify value", func(l []utils.SEOp) []utils.SEOp {
+			l[1].Sval = l[0].Sval
+			return l
+		}, ErrIncomplete},
+		{"wrong count", func(l []utils.SEOp) []utils.SEOp {
+			// id3 同时包含 a 和 b，服务器谎称不匹配
+			l[1].Cnt = 1
+			return l
+		}, ErrDishonest},
+		{"bad proof", func(l []utils.SEOp) []utils.SEOp {
+			for _, block := range odxt.Verifier.Proof.Blocks {
+				block[0] ^= 1
+			}
+			return l
+		}, ErrBadProof},
+	}
+	for _, tt := range tests {
+		sEOpList := tt.tamper(search(q...))
+		if _, err := odxt.Verify(q, sEOpList); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyLongID(t *testing.T) {
+	odxt := newTestODXT(t, NewMemoryStore())
+	if err := odxt.EnableVerification(""); err != nil {
+		t.Fatal(err)
+	}
+	long := strings.Repeat("x", 31)
+	odxt.update(utils.Add, "a", long, "id1")
+	odxt.update(utils.Add, "b", long)
+
+	q := []string{"a", "b"}
+	_, _, sEOpList, err := odxt.Search(q, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := odxt.Verify(q, sEOpList); err != nil {
+		t.Errorf("Verify(%v) = %v", q, err)
+	}
+	if ids, want := odxt.search(q...), []string{long}; !slices.Equal(ids, want) {
+		t.Errorf("Search(%v) = %v, want %v", q, ids, want)
+	}
+
+	// 会被截断的 id 在写入前被拒绝，计数不变
+	for _, id := range []string{long + "y", "id\x00"} {
+		if _, _, err := odxt.Encrypt("a", []string{"id2", id}, int(utils.Add)); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Encrypt(%q) error = %v, want %v", id, err, ErrInvalidID)
+		}
+	}
+	if odxt.UpdateCnt["a"] != 2 {
+		t.Errorf("UpdateCnt[a] = %d, want 2", odxt.UpdateCnt["a"])
+	}
+}
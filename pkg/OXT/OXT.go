@@ -0,0 +1,483 @@
+package OXT
+
+import (
+	"ConjunctiveSSE/pkg/Database"
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bits-and-blooms/bloom/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	UploadListMaxLength = 200000
+)
+
+// OXT 静态 OXT 方案 (Cash et al., CRYPTO 2013)
+//
+// TSet: 对每个关键字 w，stag = F(KT, w)，第 c 个元组保存在 F(stag, c) 处，
+// 元组为 (e, y)，e = F(Ke, c) xor id，Ke = F(KS, w)，y = Fp(KI, id) * Fp(KZ, len(w)||w||c)^-1，
+// 其中 c 与 len(w) 均编码为 8 字节大端整数。
+// XSet: xtag = g^{Fp(KX, w) * Fp(KI, id)}，保存在 Bloom filter 中。
+type OXT struct {
+	// Keys 依次为 KS, KX, KI, KZ, KT
+	Keys [5][]byte
+	// Counts 为每个关键字的文档数量，仅用于选择 s-term
+	Counts      map[string]int
+	g           *big.Int
+	p           *big.Int
+	XSet        *bloom.BloomFilter
+	PlaintextDB *mongo.Database
+	MySQLDB     *sql.DB
+	// Store 为服务器端 TSet，为 nil 时使用 MySQLDB 中以表名区分的数据表
+	Store ODXT.Store
+}
+
+// Init 初始化客户端状态和 XSet，不连接任何数据库，TSet 保存在 store 中
+func (oxt *OXT) Init(keys [5][]byte, store ODXT.Store) {
+	oxt.Keys = keys
+	oxt.Counts = make(map[string]int)
+	oxt.g = big.NewInt(65537)
+	oxt.p, _ = new(big.Int).SetString("69445180235231407255137142482031499329548634082242122837872648805446522657159", 10)
+	oxt.XSet = bloom.NewWithEstimates(1000000, 0.01)
+	oxt.Store = store
+}
+
+// store 返回保存 TSet 的存储
+func (oxt *OXT) store(tableName string) ODXT.Store {
+	if oxt.Store != nil {
+		return oxt.Store
+	}
+	return &ODXT.MySQLStore{DB: oxt.MySQLDB, TableName: tableName}
+}
+
+// TableName 返回 OXT 保存 TSet 的数据表，与 ODXT 的 dbName 表分开
+func TableName(dbName string) string {
+	return dbName + "_oxt"
+}
+
+func (oxt *OXT) DBSetup(dbName string, randomKey bool) error {
+	var keys [5][]byte
+	if randomKey {
+		// 生成5个32字节长度的随机私钥
+		for i := range keys {
+			keys[i] = make([]byte, 32)
+			if _, err := rand.Read(keys[i]); err != nil {
+				log.Println("Error generating random key:", err)
+				return err
+			}
+		}
+	} else {
+		// 与 ODXT 共用密钥文件，KT = F(KS, "KT")
+		odxtKeys := ODXT.ReadKeys("./cmd/ODXT/keys.txt")
+		copy(keys[:4], odxtKeys[:])
+		kt, err := utils.PrfF(odxtKeys[0], []byte("KT"))
+		if err != nil {
+			return err
+		}
+		keys[4] = kt
+	}
+	oxt.Init(keys, nil)
+
+	// 连接MySQL数据库，TSet 与 ODXT 使用相同的表结构
+	var err error
+	oxt.MySQLDB, err = ODXT.MySQLSetup(TableName(dbName))
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+
+	// 连接MongoDB
+	oxt.PlaintextDB, err = Database.MongoDBSetup(dbName)
+	if err != nil {
+		log.Fatal(err)
+		return err
+	}
+
+	return nil
+}
+
+func (oxt *OXT) CiphertextGenPhase(dbName string) {
+	// 获取MongoDB数据库
+	plaintextDB := oxt.PlaintextDB
+	defer plaintextDB.Client().Disconnect(context.Background())
+
+	// 初始化
+	uploadList := make([]ODXT.UpdatePayload, 0, UploadListMaxLength+1)
+	encryptTimeList := make([]time.Duration, 0, 1000000)
+	keywordList := make([]string, 0, 1000000)
+	volumeList := make([]int, 0, 1000000)
+	clientStorageUpdateBytes := make([]int, 0, 1000000)
+
+	// 从MongoDB数据库中获取名为"id_keywords"的集合
+	collection := plaintextDB.Collection("id_keywords")
+
+	// 创建一个游标，设置不超时并每次获取1000条记录
+	ctx := context.TODO()
+	opts := options.Find().SetNoCursorTimeout(true).SetBatchSize(1000)
+	cur, err := collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 关闭游标
+	defer cur.Close(ctx)
+
+	// 读取游标中的所有记录
+	var keywordIds []bson.M
+	if err = cur.All(ctx, &keywordIds); err != nil {
+		log.Fatal(err)
+	}
+
+	// 读取所有记录
+	for _, keywordId := range keywordIds {
+		valSet, ok := keywordId["val_set"].(primitive.A)
+		if !ok {
+			log.Fatal("val_set is not of type primitive.A")
+		}
+		var ids []string
+		for _, v := range valSet {
+			if str, ok := v.(string); ok {
+				ids = append(ids, str)
+			} else {
+				log.Fatal("val_set contains non-string value")
+			}
+		}
+		ids = utils.RemoveDuplicates(ids)
+		keyword := keywordId["k"].(string)
+
+		encryptTime, keywordCipher, err := oxt.Encrypt(keyword, ids)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		uploadList = append(uploadList, keywordCipher...)
+		encryptTimeList = append(encryptTimeList, encryptTime)
+		keywordList = append(keywordList, keyword)
+		volumeList = append(volumeList, len(keywordCipher))
+		clientStorageUpdateBytes = append(clientStorageUpdateBytes, ODXT.CalculateUpdatePayloadSize(keywordCipher))
+
+		// 如果上传列表的长度达到最大限制， 则将其写入数据库
+		if len(uploadList) >= UploadListMaxLength {
+			err = oxt.store(TableName(dbName)).Write(uploadList)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			// 清空上传列表
+			uploadList = make([]ODXT.UpdatePayload, 0, UploadListMaxLength+1)
+		}
+	}
+
+	// 如果上传列表不为空， 则将其写入数据库
+	if len(uploadList) > 0 {
+		err = oxt.store(TableName(dbName)).Write(uploadList)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	saveTime := time.Now()
+	// 保存 XSet 到文件
+	err = utils.SaveBloomFilterToFile(oxt.XSet, filepath.Join("result", "Update", "OXT", fmt.Sprintf("%s_%s_XSet.bin", dbName, saveTime.Format("2006-01-02_15-04-05"))))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 保存 oxt.Counts 到文件
+	err = utils.SaveUpdateCntToFile(oxt.Counts, filepath.Join("result", "Update", "OXT", fmt.Sprintf("%s_%s_Counts.json", dbName, saveTime.Format("2006-01-02_15-04-05"))))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 设置结果文件的路径和名称
+	resultpath := filepath.Join("result", "Update", "OXT", fmt.Sprintf("%s_%s.csv", dbName, saveTime.Format("2006-01-02_15-04-05")))
+
+	// 定义结果表头
+	resultHeader := []string{"keyword", "volume", "addTime", "storageUpdateBytes"}
+
+	// 将结果数据整理成表格形式
+	resultData := make([][]string, len(keywordList))
+	for i, keyword := range keywordList {
+		resultData[i] = []string{keyword, strconv.Itoa(volumeList[i]), encryptTimeList[i].String(), strconv.Itoa(clientStorageUpdateBytes[i])}
+	}
+
+	// 将结果写入文件
+	err = utils.WriteResultToCSV(resultpath, resultHeader, resultData)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// stag 计算 TSet 标签 stag = F(KT, w)
+func (oxt *OXT) stag(keyword string) ([]byte, error) {
+	return utils.PrfF(oxt.Keys[4], []byte(keyword))
+}
+
+// Encrypt 为关键字 keyword 的全部文档生成 TSet 元组并写入 XSet，每个关键字只能调用一次
+func (oxt *OXT) Encrypt(keyword string, ids []string) (time.Duration, []ODXT.UpdatePayload, error) {
+	ks, kx, ki, kz := oxt.Keys[0], oxt.Keys[1], oxt.Keys[2], oxt.Keys[3]
+	p, g := oxt.p, oxt.g
+	pMinus1 := new(big.Int).Sub(p, big.NewInt(1))
+
+	if _, ok := oxt.Counts[keyword]; ok {
+		return 0, nil, fmt.Errorf("OXT is static: keyword %s already encrypted", keyword)
+	}
+
+	start := time.Now()
+	ke, err := utils.PrfF(ks, []byte(keyword))
+	if err != nil {
+		return 0, nil, err
+	}
+	stag, err := oxt.stag(keyword)
+	if err != nil {
+		return 0, nil, err
+	}
+	xw, err := utils.PrfFp(kx, []byte(keyword), p, g)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	tuples := make([]ODXT.UpdatePayload, len(ids))
+	for i, id := range ids {
+		c := counterBytes(i + 1)
+
+		// address = F(stag, c)
+		address, err := utils.PrfF(stag, c)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// e = F(Ke, c) xor id
+		pad, err := utils.PrfF(ke, c)
+		if err != nil {
+			return 0, nil, err
+		}
+		if len(id) > len(pad) {
+			return 0, nil, fmt.Errorf("id %s is longer than %d bytes", id, len(pad))
+		}
+		e := utils.BytesXOR(pad, append([]byte(id), make([]byte, len(pad)-len(id))...))
+
+		// y = xind * z^-1
+		xind, err := utils.PrfFp(ki, []byte(id), p, g)
+		if err != nil {
+			return 0, nil, err
+		}
+		z, err := utils.PrfFp(kz, zInput(keyword, i+1), p, g)
+		if err != nil {
+			return 0, nil, err
+		}
+		zInv := new(big.Int).ModInverse(z, pMinus1)
+		if zInv == nil {
+			return 0, nil, fmt.Errorf("z is not invertible for keyword %s", keyword)
+		}
+		y := new(big.Int).Mod(new(big.Int).Mul(xind, zInv), pMinus1)
+
+		// xtag = g^{Fp(KX, w) * xind}
+		xtag := new(big.Int).Exp(g, new(big.Int).Mul(xw, xind), p)
+		oxt.XSet.Add(xtag.Bytes())
+
+		tuples[i] = ODXT.UpdatePayload{
+			Address: base64.StdEncoding.EncodeToString(address),
+			Val:     base64.StdEncoding.EncodeToString(e),
+			Alpha:   base64.StdEncoding.EncodeToString(y.Bytes()),
+		}
+	}
+	oxt.Counts[keyword] = len(ids)
+
+	return time.Since(start), tuples, nil
+}
+
+func (oxt *OXT) SearchPhase(dbName, fileName string) {
+	tableName := TableName(dbName)
+	// 与 ODXT 共用查询文件
+	fileName = "./cmd/ODXT/" + fileName
+	keywordsList := utils.QueryKeywordsFromFile(fileName)
+
+	// 初始化结果列表
+	resultData := make([][]string, 0, len(keywordsList))
+
+	// 循环搜索
+	for _, keywords := range keywordsList {
+		clientTime, serverTime, sIdList, err := oxt.Search(keywords, tableName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resultData = append(resultData, []string{strings.Join(keywords, "#"), clientTime.String(), serverTime.String(), strconv.Itoa(len(sIdList))})
+	}
+
+	// 设置结果文件的路径和名称
+	resultpath := filepath.Join("result", "Search", "OXT", fmt.Sprintf("%s_%s.csv", tableName, time.Now().Format("2006-01-02_15-04-05")))
+
+	// 定义结果表头
+	resultHeader := []string{"keyword", "clientSearchTime", "serverTime", "resultLength"}
+
+	// 将结果写入文件
+	err := utils.WriteResultToCSV(resultpath, resultHeader, resultData)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Search 执行连接查询，返回客户端耗时、服务器耗时和结果
+func (oxt *OXT) Search(q []string, tableName string) (time.Duration, time.Duration, []string, error) {
+	// 选择文档数最少的关键字作为 s-term
+	counter, w1 := math.MaxInt64, q[0]
+	for _, w := range q {
+		if num := oxt.Counts[w]; num < counter {
+			w1 = w
+			counter = num
+		}
+	}
+	xterms := utils.RemoveElement(q, w1)
+
+	// client: stag 和 xtoken
+	start := time.Now()
+	stag, err := oxt.stag(w1)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	xtokenList, err := oxt.xtokens(w1, xterms, counter)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	clientTime := time.Since(start)
+
+	// server: 取回 TSet 元组并过滤
+	start = time.Now()
+	tuples, err := TSetRetrieve(oxt.store(tableName), stag)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(tuples) != counter {
+		return 0, 0, nil, fmt.Errorf("server returned %d tuples for %q, client expects %d", len(tuples), w1, counter)
+	}
+	eList := make(map[int]string, len(tuples))
+	for c, tuple := range tuples {
+		y, err := utils.Base64ToBigInt(tuple.Alpha)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		matched := true
+		for _, xtoken := range xtokenList[c] {
+			xtag := new(big.Int).Exp(xtoken, y, oxt.p)
+			if !oxt.XSet.Test(xtag.Bytes()) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			eList[c+1] = tuple.Value
+		}
+	}
+	serverTime := time.Since(start)
+
+	// client: 解密
+	start = time.Now()
+	ids, err := oxt.Decrypt(w1, eList)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	clientTime += time.Since(start)
+
+	return clientTime, serverTime, ids, nil
+}
+
+// counterBytes 元组序号 c 的 8 字节大端编码
+func counterBytes(c int) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(c))
+}
+
+// zInput KZ 的 PRF 输入 len(w)||w||c，长度前缀使关键字与序号的边界唯一
+func zInput(w string, c int) []byte {
+	in := binary.BigEndian.AppendUint64(nil, uint64(len(w)))
+	in = append(in, w...)
+	return append(in, counterBytes(c)...)
+}
+
+// xtokens 计算 xtoken[c][i] = g^{Fp(KZ, len(w1)||w1||c) * Fp(KX, wi)}
+func (oxt *OXT) xtokens(w1 string, xterms []string, n int) ([][]*big.Int, error) {
+	kx, kz := oxt.Keys[1], oxt.Keys[3]
+	pMinus1 := new(big.Int).Sub(oxt.p, big.NewInt(1))
+
+	xws := make([]*big.Int, len(xterms))
+	for i, wi := range xterms {
+		xw, err := utils.PrfFp(kx, []byte(wi), oxt.p, oxt.g)
+		if err != nil {
+			return nil, err
+		}
+		xws[i] = xw
+	}
+
+	xtokenList := make([][]*big.Int, n)
+	for c := 0; c < n; c++ {
+		z, err := utils.PrfFp(kz, zInput(w1, c+1), oxt.p, oxt.g)
+		if err != nil {
+			return nil, err
+		}
+		xtokenList[c] = make([]*big.Int, len(xterms))
+		for i, xw := range xws {
+			head := new(big.Int).Mod(new(big.Int).Mul(z, xw), pMinus1)
+			xtokenList[c][i] = new(big.Int).Exp(oxt.g, head, oxt.p)
+		}
+	}
+	return xtokenList, nil
+}
+
+// TSetRetrieve 服务器根据 stag 按 c = 1, 2, ... 取回 TSet 元组，直到第一个不存在的地址
+func TSetRetrieve(store ODXT.Store, stag []byte) ([]ODXT.SearchPayload, error) {
+	tuples := make([]ODXT.SearchPayload, 0)
+	for c := 1; ; c++ {
+		addr, err := utils.PrfF(stag, counterBytes(c))
+		if err != nil {
+			return nil, err
+		}
+		payloads, err := store.Search([]string{base64.StdEncoding.EncodeToString(addr)})
+		if errors.Is(err, ODXT.ErrNotFound) {
+			return tuples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tuples = append(tuples, payloads[0])
+	}
+}
+
+// Decrypt 使用 Ke = F(KS, w1) 解密服务器返回的 e，eList 的键为元组序号 c
+func (oxt *OXT) Decrypt(w1 string, eList map[int]string) ([]string, error) {
+	ke, err := utils.PrfF(oxt.Keys[0], []byte(w1))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(eList))
+	for c, e := range eList {
+		pad, err := utils.PrfF(ke, counterBytes(c))
+		if err != nil {
+			return nil, err
+		}
+		eBytes, err := base64.StdEncoding.DecodeString(e)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, string(bytes.TrimRight(utils.BytesXOR(eBytes, pad), "\x00")))
+	}
+	return ids, nil
+}
@@ -0,0 +1,293 @@
+// Package attack 在记录的服务器端泄露上模拟泄露滥用攻击（计数攻击、体积匹配攻击），
+// 攻击者已知数据集中一部分文档，评估查询关键字的恢复率。
+package attack
+
+import (
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	mrand "math/rand"
+	"slices"
+	"sort"
+)
+
+// z 判断观察值与已知文档估计值是否一致时允许的标准差倍数
+const z = 2.0
+
+// Observation 服务器对一次查询的观察
+type Observation struct {
+	// Tag 为查询模式，同一关键字的查询具有相同的 Tag（第一个 s-term 地址）
+	Tag string
+	// Volume 为 s-term 列表长度，包括填充条目
+	Volume int
+	// Results 为客户端取回的文档句柄
+	Results []string
+}
+
+// Observe 从泄露事件中提取查询的观察，没有访问任何地址的查询被忽略
+func Observe(events []leakage.Event) []Observation {
+	obs := make([]Observation, 0, len(events))
+	for _, e := range events {
+		if e.Type != "search" || len(e.Addresses) == 0 {
+			continue
+		}
+		obs = append(obs, Observation{Tag: e.Addresses[0], Volume: len(e.Addresses), Results: e.Results})
+	}
+	return obs
+}
+
+// Handles 模拟服务器保存加密文档时使用的文档句柄，客户端取回文档时服务器观察到句柄
+type Handles struct {
+	key []byte
+}
+
+// NewHandles 使用随机密钥创建文档句柄
+func NewHandles() (*Handles, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return &Handles{key: key}, nil
+}
+
+// Handle 返回文档 id 的句柄
+func (h *Handles) Handle(id string) string {
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Simulate 依次执行单关键字查询 queries，每次查询的泄露和取回的文档句柄记录为 r 中的一个事件。
+// s 需已设置为向 r 记录泄露。
+func Simulate(s scheme.Scheme, r *leakage.Recorder, h *Handles, queries []string) error {
+	for _, w := range queries {
+		r.Begin(s.Name(), "search")
+		ids, _, err := s.Search([]string{w})
+		if err != nil {
+			r.End()
+			return err
+		}
+		handles := make([]string, len(ids))
+		for i, id := range ids {
+			handles[i] = h.Handle(id)
+		}
+		r.Record(func(e *leakage.Event) {
+			e.Results = append(e.Results, handles...)
+		})
+		if err := r.End(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SampleQueries 从 keywords 中无放回地均匀抽取 n 个查询关键字
+func SampleQueries(keywords []string, n int, rng *mrand.Rand) []string {
+	queries := slices.Clone(keywords)
+	rng.Shuffle(len(queries), func(i, j int) { queries[i], queries[j] = queries[j], queries[i] })
+	return queries[:min(n, len(queries))]
+}
+
+// Knowledge 攻击者已知的文档
+type Knowledge struct {
+	// Fraction 为已知文档占数据集的比例
+	Fraction float64
+	// Counts 为已知文档中每个关键字的出现次数
+	Counts map[string]int
+	// docs 为包含每个关键字的已知文档下标，升序
+	docs map[string][]int
+}
+
+// Known 从 dataset 中随机选取 fraction 比例的文档作为攻击者的已知文档
+func Known(dataset scheme.Dataset, fraction float64, rng *mrand.Rand) *Knowledge {
+	k := &Knowledge{Fraction: fraction, Counts: make(map[string]int), docs: make(map[string][]int)}
+	n := int(math.Round(fraction * float64(len(dataset))))
+	known := rng.Perm(len(dataset))[:n]
+	sort.Ints(known)
+	for _, i := range known {
+		for _, w := range dataset[i].Keywords {
+			k.Counts[w]++
+			k.docs[w] = append(k.docs[w], i)
+		}
+	}
+	return k
+}
+
+// cooccur 已知文档中同时包含 w1 和 w2 的文档数
+func (k *Knowledge) cooccur(w1, w2 string) int {
+	a, b := k.docs[w1], k.docs[w2]
+	cnt := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			cnt++
+			i++
+			j++
+		}
+	}
+	return cnt
+}
+
+// consistent 真实数量为 n 时，已知文档中的数量 known 是否在期望值 f·n 的 z 个标准差内
+func (k *Knowledge) consistent(known int, n float64) bool {
+	f := k.Fraction
+	return math.Abs(float64(known)-f*n) <= z*math.Sqrt(n*f*(1-f))+0.5
+}
+
+// keywords 已知文档中出现的关键字，按字典序排列
+func (k *Knowledge) keywords() []string {
+	keywords := make([]string, 0, len(k.Counts))
+	for w := range k.Counts {
+		keywords = append(keywords, w)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// VolumeModel 返回计数为 n 的关键字可能被观察到的 s-term 列表长度范围
+type VolumeModel func(n int) (lo, hi int)
+
+// Exact 不填充时观察到的长度即为计数
+func Exact(n int) (int, int) {
+	return n, n
+}
+
+// distinct 按 Tag 合并观察，返回首次出现顺序的 Tag 及对应的观察
+func distinct(obs []Observation) ([]string, map[string]Observation) {
+	tags := make([]string, 0, len(obs))
+	byTag := make(map[string]Observation, len(obs))
+	for _, o := range obs {
+		if _, ok := byTag[o.Tag]; !ok {
+			tags = append(tags, o.Tag)
+			byTag[o.Tag] = o
+		}
+	}
+	return tags, byTag
+}
+
+// VolumeAttack 体积匹配攻击：由已知文档估计每个关键字的计数，按 model 得到可能的 s-term 列表长度，
+// 与观察到的长度一致的关键字唯一时恢复该查询。返回 Tag -> 关键字。
+func VolumeAttack(obs []Observation, k *Knowledge, model VolumeModel) map[string]string {
+	tags, byTag := distinct(obs)
+	keywords := k.keywords()
+	f := k.Fraction
+	guesses := make(map[string]string)
+	for _, tag := range tags {
+		v := float64(byTag[tag].Volume)
+		guess, found := "", 0
+		for _, w := range keywords {
+			// 估计的真实计数及其标准差
+			est := float64(k.Counts[w]) / f
+			tol := z*math.Sqrt(est*(1-f)/f) + 0.5
+			lo, hi := model(int(math.Round(est)))
+			if v >= float64(lo)-tol && v <= float64(hi)+tol {
+				guess = w
+				found++
+				if found > 1 {
+					break
+				}
+			}
+		}
+		if found == 1 {
+			guesses[tag] = guess
+		}
+	}
+	return guesses
+}
+
+// CountAttack Cash 等人的计数攻击：先用结果数量唯一确定部分查询，
+// 再用查询结果的共现数量与已知文档中关键字的共现数量逐步排除候选。返回 Tag -> 关键字。
+func CountAttack(obs []Observation, k *Knowledge) map[string]string {
+	tags, byTag := distinct(obs)
+	keywords := k.keywords()
+	results := make(map[string]map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		set := make(map[string]struct{}, len(byTag[tag].Results))
+		for _, h := range byTag[tag].Results {
+			set[h] = struct{}{}
+		}
+		results[tag] = set
+	}
+	overlap := func(t1, t2 string) int {
+		cnt := 0
+		for h := range results[t1] {
+			if _, ok := results[t2][h]; ok {
+				cnt++
+			}
+		}
+		return cnt
+	}
+
+	// 结果数量一致的候选关键字
+	candidates := make(map[string][]string, len(tags))
+	for _, tag := range tags {
+		n := float64(len(results[tag]))
+		for _, w := range keywords {
+			if k.consistent(k.Counts[w], n) {
+				candidates[tag] = append(candidates[tag], w)
+			}
+		}
+	}
+
+	guesses := make(map[string]string)
+	used := make(map[string]bool)
+	assign := func(tag, w string) {
+		guesses[tag] = w
+		used[w] = true
+	}
+	for _, tag := range tags {
+		if len(candidates[tag]) == 1 && !used[candidates[tag][0]] {
+			assign(tag, candidates[tag][0])
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, tag := range tags {
+			if _, ok := guesses[tag]; ok {
+				continue
+			}
+			remaining := candidates[tag][:0]
+			for _, w := range candidates[tag] {
+				if used[w] {
+					continue
+				}
+				ok := true
+				for known, kw := range guesses {
+					if !k.consistent(k.cooccur(w, kw), float64(overlap(tag, known))) {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					remaining = append(remaining, w)
+				}
+			}
+			candidates[tag] = remaining
+			if len(remaining) == 1 {
+				assign(tag, remaining[0])
+				changed = true
+			}
+		}
+	}
+	return guesses
+}
+
+// Recovered 统计 obs 中被正确恢复的查询数，truth[i] 为 obs[i] 的真实关键字
+func Recovered(obs []Observation, truth []string, guesses map[string]string) int {
+	cnt := 0
+	for i, o := range obs {
+		if w, ok := guesses[o.Tag]; ok && w == truth[i] {
+			cnt++
+		}
+	}
+	return cnt
+}
@@ -0,0 +1,87 @@
+package attack
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"fmt"
+	mrand "math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// testDataset 关键字 w0..w5 分别出现在前 1、4、7、10、13、16 个文档中
+func testDataset() (scheme.Dataset, []string) {
+	dataset := make(scheme.Dataset, 16)
+	keywords := make([]string, 6)
+	for i := range dataset {
+		dataset[i].ID = fmt.Sprintf("id%d", i)
+	}
+	for j := range keywords {
+		keywords[j] = fmt.Sprintf("w%d", j)
+		for i := 0; i < 3*j+1; i++ {
+			dataset[i].Keywords = append(dataset[i].Keywords, keywords[j])
+		}
+	}
+	return dataset, keywords
+}
+
+func observe(t *testing.T, padding ODXT.PadMode, dataset scheme.Dataset, queries []string) (*scheme.ODXTScheme, []Observation) {
+	s, err := scheme.NewODXT(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Padding = padding
+	if err := s.Setup(dataset); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "leakage.jsonl")
+	recorder, err := leakage.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Recorder = recorder
+	handles, err := NewHandles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Simulate(s, recorder, handles, queries); err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	events, err := leakage.ReadEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, Observe(events)
+}
+
+func TestAttacks(t *testing.T) {
+	dataset, keywords := testDataset()
+	queries := SampleQueries(keywords, len(keywords), mrand.New(mrand.NewSource(1)))
+	known := Known(dataset, 1, mrand.New(mrand.NewSource(1)))
+
+	tests := []struct {
+		padding     ODXT.PadMode
+		volume, cnt int
+	}{
+		{ODXT.NoPadding, 6, 6},
+		// 填充到 2 的幂后 w3、w4、w5 的长度均为 16，体积匹配无法区分，结果数量不受填充影响
+		{ODXT.PadPowerOfTwo, 3, 6},
+	}
+	for _, tt := range tests {
+		s, obs := observe(t, tt.padding, dataset, queries)
+		if len(obs) != len(queries) {
+			t.Fatalf("%v: got %d observations, want %d", tt.padding, len(obs), len(queries))
+		}
+		if got := Recovered(obs, queries, VolumeAttack(obs, known, s.VolumeRange)); got != tt.volume {
+			t.Errorf("%v: volume attack recovered %d, want %d", tt.padding, got, tt.volume)
+		}
+		if got := Recovered(obs, queries, CountAttack(obs, known)); got != tt.cnt {
+			t.Errorf("%v: count attack recovered %d, want %d", tt.padding, got, tt.cnt)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package attack
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"ConjunctiveSSE/pkg/utils"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+)
+
+// Injection Zhang 等人的二分文件注入攻击：第 i 个注入文件包含下标 k 的第 i 位为 1 的关键字
+// （k 从 1 开始），查询匹配的注入文件集合即为其关键字下标的二进制表示。
+// Past 中的查询在注入之前执行，Future 中的查询在注入之后执行。
+type Injection struct {
+	Keywords []string
+	Past     []string
+	Future   []string
+	files    [][]string
+	handles  *Handles
+}
+
+// NewInjection 为关键字全集 keywords 生成 ⌈log2(|keywords|+1)⌉ 个注入文件
+func NewInjection(keywords, past, future []string) (*Injection, error) {
+	handles, err := NewHandles()
+	if err != nil {
+		return nil, err
+	}
+	files := make([][]string, bits.Len(uint(len(keywords))))
+	for k, w := range keywords {
+		for i := range files {
+			if (k+1)>>i&1 == 1 {
+				files[i] = append(files[i], w)
+			}
+		}
+	}
+	return &Injection{Keywords: keywords, Past: past, Future: future, files: files, handles: handles}, nil
+}
+
+// Files 返回注入文件数及注入的 (关键字, 文件) 对数
+func (inj *Injection) Files() (files, entries int) {
+	for _, f := range inj.files {
+		entries += len(f)
+	}
+	return len(inj.files), entries
+}
+
+// Run 依次执行 Past 中的查询、注入文件、Future 中的查询，每个注入文件的更新记录为 r 中的一个事件。
+// s 需已设置为向 r 记录查询和更新写入的地址。
+func (inj *Injection) Run(s scheme.Scheme, r *leakage.Recorder) error {
+	if err := Simulate(s, r, inj.handles, inj.Past); err != nil {
+		return err
+	}
+	for i, f := range inj.files {
+		r.Begin(s.Name(), "update")
+		err := s.Update(utils.Add, fmt.Sprintf("injected%d", i), f)
+		if eerr := r.End(); err == nil {
+			err = eerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return Simulate(s, r, inj.handles, inj.Future)
+}
+
+// Recover 根据 Run 记录的泄露恢复查询关键字，返回 Past 和 Future 中被正确恢复的查询数。
+// 注入之前的查询只能通过搜索令牌与注入文件写入的地址关联，前向安全的方案中无法恢复；
+// 注入之后的查询通过取回的注入文件句柄恢复。
+func (inj *Injection) Recover(events []leakage.Event) (past, future int, err error) {
+	searches := make([]leakage.Event, 0, len(events))
+	updates := make([]leakage.Event, 0, len(events))
+	for _, e := range events {
+		switch e.Type {
+		case "search":
+			searches = append(searches, e)
+		case "update":
+			updates = append(updates, e)
+		}
+	}
+	if len(searches) < len(inj.Past)+len(inj.Future) || len(updates) < len(inj.files) {
+		return 0, 0, fmt.Errorf("got %d searches and %d updates, want %d and %d",
+			len(searches), len(updates), len(inj.Past)+len(inj.Future), len(inj.files))
+	}
+	futureEvents := searches[len(searches)-len(inj.Future):]
+	pastEvents := searches[len(searches)-len(inj.Future)-len(inj.Past) : len(searches)-len(inj.Future)]
+	injected := updates[len(updates)-len(inj.files):]
+
+	// 注入文件写入的地址
+	written := make([]map[string]struct{}, len(injected))
+	for i, e := range injected {
+		written[i] = make(map[string]struct{}, len(e.Addresses))
+		for _, address := range e.Addresses {
+			written[i][address] = struct{}{}
+		}
+	}
+	for i, e := range pastEvents {
+		if e.Token == "" {
+			continue
+		}
+		t, err := base64.StdEncoding.DecodeString(e.Token)
+		if err != nil {
+			return 0, 0, err
+		}
+		// 由地址令牌计算查询时已有条目之后的地址，检查它们由哪些注入文件写入
+		k := 0
+		for j := len(e.Addresses) + 1; j <= len(e.Addresses)+len(inj.files); j++ {
+			address, err := ODXT.TokenAddress(t, j)
+			if err != nil {
+				return 0, 0, err
+			}
+			for f := range written {
+				if _, ok := written[f][address]; ok {
+					k |= 1 << f
+				}
+			}
+		}
+		if inj.keyword(k) == inj.Past[i] {
+			past++
+		}
+	}
+
+	for i, e := range futureEvents {
+		results := make(map[string]struct{}, len(e.Results))
+		for _, h := range e.Results {
+			results[h] = struct{}{}
+		}
+		k := 0
+		for f := range inj.files {
+			if _, ok := results[inj.handles.Handle(fmt.Sprintf("injected%d", f))]; ok {
+				k |= 1 << f
+			}
+		}
+		if inj.keyword(k) == inj.Future[i] {
+			future++
+		}
+	}
+	return past, future, nil
+}
+
+// keyword 由匹配的注入文件集合 k 得到关键字，k 为 0 时没有匹配
+func (inj *Injection) keyword(k int) string {
+	if k == 0 || k > len(inj.Keywords) {
+		return ""
+	}
+	return inj.Keywords[k-1]
+}
@@ -0,0 +1,67 @@
+package attack
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/leakage"
+	"ConjunctiveSSE/pkg/scheme"
+	"path/filepath"
+	"testing"
+)
+
+func TestInjection(t *testing.T) {
+	dataset, keywords := testDataset()
+	inj, err := NewInjection(keywords, []string{"w1", "w4"}, []string{"w2", "w5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files, entries := inj.Files(); files != 3 || entries != 9 {
+		t.Errorf("Files() = %d, %d; want 3, 9", files, entries)
+	}
+
+	tests := []struct {
+		name         string
+		past, future int
+	}{
+		// 前向安全：注入的条目无法与之前的查询关联
+		{"ODXT", 0, 2},
+		{"ODXTNonFP", 2, 2},
+	}
+	for _, tt := range tests {
+		store := &ODXT.RecordingStore{Store: ODXT.NewMemoryStore(), Scheme: tt.name}
+		odxt, err := scheme.NewODXT(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s scheme.Scheme = odxt
+		if tt.name == "ODXTNonFP" {
+			s = &scheme.ODXTNonFPScheme{ODXTScheme: odxt}
+		}
+		if err := s.Setup(dataset); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(t.TempDir(), "leakage.jsonl")
+		recorder, err := leakage.NewRecorder(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		store.Recorder, odxt.Recorder = recorder, recorder
+		if err := inj.Run(s, recorder); err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+		events, err := leakage.ReadEvents(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		past, future, err := inj.Recover(events)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if past != tt.past || future != tt.future {
+			t.Errorf("%s: recovered %d past, %d future; want %d, %d", tt.name, past, future, tt.past, tt.future)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Package auhme 实现 AUHME (Authorized Updatable Hidden Map Encryption)。
+//
+// 客户端持有密钥与编辑缓存，服务器只保存 label -> 密文 的映射。
+// 加密：label = F(k1, k)，enc = F(k2, label||v) xor F(k3, label||cnt)。
+// 查询：客户端给出 label 列表 L、随机数 r 和 d = H(r || xor(F(k2, l||v) xor F(k3, l||cnt)))，
+// 服务器对 L 中的密文求异或后计算 H(r || xor)，与 d 相等即说明映射满足查询条件。
+// label 与密文均为定长的 16 字节值，所有聚合都是按字节异或。
+package auhme
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+	"crypto/sha256"
+)
+
+const KeyLen = 16
+
+type Operation int
+
+const (
+	Add Operation = iota
+	Edit
+)
+
+// Block AUHME 中的 label 和密文
+type Block [16]byte
+
+// Client AUHME 客户端状态
+type Client struct {
+	Keys [3][]byte
+	// cnt 为当前的加密轮次，每次缓存清空（evict）后加一
+	cnt int
+	// t 为编辑缓存，保存尚未下发到服务器的 label -> v
+	t map[Block]int
+	// delta 为缓存容量，缓存满时对所有 label 重新加密
+	delta int
+	// labels 为服务器上已存在的 label 集合
+	labels map[Block]struct{}
+	// ones 为当前值为 1 的 label（含缓存中尚未下发的修改），Edit 只在值改变时翻转密文
+	ones map[Block]struct{}
+}
+
+// Server AUHME 服务器状态
+type Server struct {
+	EDB map[Block]Block
+}
+
+// UpdateToken 更新令牌，Tok 为 label -> 更新值
+type UpdateToken struct {
+	Tok map[Block]Block
+	Op  Operation
+}
+
+// DecryptionKey 查询密钥
+type DecryptionKey struct {
+	L []Block
+	r Block
+	d [sha256.Size]byte
+}
+
+// KeyGen 生成随机密钥并返回客户端，delta 为编辑缓存的容量
+func KeyGen(delta int) (*Client, error) {
+	var keys [3][]byte
+	for i := range keys {
+		keys[i] = make([]byte, KeyLen)
+		if _, err := rand.Read(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return NewClient(keys, delta), nil
+}
+
+// NewClient 使用给定密钥创建客户端
+func NewClient(keys [3][]byte, delta int) *Client {
+	return &Client{
+		Keys:   keys,
+		t:      make(map[Block]int),
+		delta:  delta,
+		labels: make(map[Block]struct{}),
+		ones:   make(map[Block]struct{}),
+	}
+}
+
+// CacheEntry 编辑缓存中的一项
+type CacheEntry struct {
+	Label Block
+	V     int
+}
+
+// State 客户端的可持久化状态（不含密钥）
+type State struct {
+	Cnt    int
+	Delta  int
+	Cache  []CacheEntry
+	Labels []Block
+	Ones   []Block
+}
+
+// State 导出客户端状态
+func (c *Client) State() *State {
+	st := &State{
+		Cnt:    c.cnt,
+		Delta:  c.delta,
+		Cache:  make([]CacheEntry, 0, len(c.t)),
+		Labels: make([]Block, 0, len(c.labels)),
+	}
+	for l, v := range c.t {
+		st.Cache = append(st.Cache, CacheEntry{l, v})
+	}
+	for l := range c.labels {
+		st.Labels = append(st.Labels, l)
+	}
+	for l := range c.ones {
+		st.Ones = append(st.Ones, l)
+	}
+	return st
+}
+
+// Restore 从导出的状态恢复客户端
+func (c *Client) Restore(st *State) {
+	c.cnt, c.delta = st.Cnt, st.Delta
+	c.t = make(map[Block]int, len(st.Cache))
+	for _, e := range st.Cache {
+		c.t[e.Label] = e.V
+	}
+	c.labels = make(map[Block]struct{}, len(st.Labels))
+	for _, l := range st.Labels {
+		c.labels[l] = struct{}{}
+	}
+	c.ones = make(map[Block]struct{}, len(st.Ones))
+	for _, l := range st.Ones {
+		c.ones[l] = struct{}{}
+	}
+}
+
+// NewServer 创建空的服务器
+func NewServer() *Server {
+	return &Server{EDB: make(map[Block]Block)}
+}
+
+// Cnt 返回当前的加密轮次
+func (c *Client) Cnt() int {
+	return c.cnt
+}
+
+// prf 计算 F(key, l||b)，输出截断为 16 字节
+func prf(key []byte, l Block, b int) (Block, error) {
+	var out Block
+	res, err := utils.FAesni(key, append(l[:], byte(b)), 1)
+	if err != nil {
+		return out, err
+	}
+	copy(out[:], res)
+	return out, nil
+}
+
+// Label 计算 label = F(k1, k)
+func (c *Client) Label(k string) (Block, error) {
+	var l Block
+	res, err := utils.FAesni(c.Keys[0], []byte(k), 1)
+	if err != nil {
+		return l, err
+	}
+	copy(l[:], res)
+	return l, nil
+}
+
+// enc 计算 F(k2, l||v) xor F(k3, l||cnt)
+func (c *Client) enc(l Block, v, cnt int) (Block, error) {
+	enc1, err := prf(c.Keys[1], l, v)
+	if err != nil {
+		return Block{}, err
+	}
+	enc2, err := prf(c.Keys[2], l, cnt)
+	if err != nil {
+		return Block{}, err
+	}
+	return enc1.Xor(enc2), nil
+}
+
+// Encrypt 加密映射中的一项 (k, v)，返回 label 和密文
+func (c *Client) Encrypt(k string, v int) (Block, Block, error) {
+	l, err := c.Label(k)
+	if err != nil {
+		return Block{}, Block{}, err
+	}
+	enc, err := c.enc(l, v, c.cnt)
+	if err != nil {
+		return Block{}, Block{}, err
+	}
+	c.labels[l] = struct{}{}
+	c.setValue(l, v)
+	return l, enc, nil
+}
+
+// setValue 记录 label 的当前值
+func (c *Client) setValue(l Block, v int) {
+	if v == 1 {
+		c.ones[l] = struct{}{}
+	} else {
+		delete(c.ones, l)
+	}
+}
+
+// Value 返回 k 的当前值，不在服务器上的 k 视为 0
+func (c *Client) Value(k string) (int, error) {
+	l, err := c.Label(k)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := c.ones[l]; ok {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Contains 判断 k 对应的 label 是否已在服务器上
+func (c *Client) Contains(k string) (bool, error) {
+	l, err := c.Label(k)
+	if err != nil {
+		return false, err
+	}
+	_, ok := c.labels[l]
+	return ok, nil
+}
+
+// Dummy 生成一条随机的填充条目，label 与密文均不可与真实条目区分。
+// 填充 label 同样参与 evict 时的重新加密。
+func (c *Client) Dummy() (Block, Block, error) {
+	var l, enc Block
+	if _, err := rand.Read(l[:]); err != nil {
+		return l, enc, err
+	}
+	if _, err := rand.Read(enc[:]); err != nil {
+		return l, enc, err
+	}
+	c.labels[l] = struct{}{}
+	return l, enc, nil
+}
+
+// GenUpd 生成更新令牌。
+// op == Add 时直接返回新 label 的密文；op == Edit 时先写入缓存，
+// 缓存未满返回 nil，缓存满时对所有 label 重新加密并清空缓存。
+// 下发时缓存中的 label 会翻转密文，因此值未改变的 Edit 不做任何操作。
+func (c *Client) GenUpd(op Operation, k string, v int) (*UpdateToken, error) {
+	if op == Add {
+		label, enc, err := c.Encrypt(k, v)
+		if err != nil {
+			return nil, err
+		}
+		return &UpdateToken{Tok: map[Block]Block{label: enc}, Op: Add}, nil
+	}
+
+	l, err := c.Label(k)
+	if err != nil {
+		return nil, err
+	}
+	if _, one := c.ones[l]; one == (v == 1) {
+		return nil, nil
+	}
+	c.setValue(l, v)
+	if _, cached := c.t[l]; cached {
+		// 撤销缓存中尚未下发的修改，服务器上保存的正是 v
+		delete(c.t, l)
+		return nil, nil
+	}
+	c.t[l] = v
+	if len(c.t)+1 < c.delta {
+		return nil, nil
+	}
+
+	tok, err := c.cEvict()
+	if err != nil {
+		return nil, err
+	}
+	c.cClear()
+	c.cnt++
+	return &UpdateToken{Tok: tok, Op: Edit}, nil
+}
+
+// cEvict 为所有 label 生成重新加密的令牌
+func (c *Client) cEvict() (map[Block]Block, error) {
+	tok := make(map[Block]Block, len(c.labels))
+	for l := range c.labels {
+		u3, err := prf(c.Keys[2], l, c.cnt)
+		if err != nil {
+			return nil, err
+		}
+		u4, err := prf(c.Keys[2], l, c.cnt+1)
+		if err != nil {
+			return nil, err
+		}
+		u := u3.Xor(u4)
+
+		// 缓存中的 label 同时翻转其值
+		if b, ok := c.t[l]; ok {
+			u1, err := prf(c.Keys[1], l, b)
+			if err != nil {
+				return nil, err
+			}
+			u2, err := prf(c.Keys[1], l, 1-b)
+			if err != nil {
+				return nil, err
+			}
+			u = u.Xor(u1).Xor(u2)
+		}
+		tok[l] = u
+	}
+	return tok, nil
+}
+
+// cClear 清空缓存
+func (c *Client) cClear() {
+	c.t = make(map[Block]int)
+}
+
+// GenKey 为查询条件 m (k -> v) 生成查询密钥
+func (c *Client) GenKey(m map[string]int) (*DecryptionKey, error) {
+	L := make([]Block, 0, len(m))
+	beta := true
+	var xors Block
+	for k, v := range m {
+		l, err := c.Label(k)
+		if err != nil {
+			return nil, err
+		}
+		L = append(L, l)
+
+		cv, cached := c.t[l]
+		switch {
+		case cached && cv != v:
+			// 缓存中的值与查询条件不符
+			beta = false
+		case cached:
+			// 服务器上保存的仍是旧值 1-v
+			e, err := c.enc(l, 1-v, c.cnt)
+			if err != nil {
+				return nil, err
+			}
+			xors = xors.Xor(e)
+		default:
+			e, err := c.enc(l, v, c.cnt)
+			if err != nil {
+				return nil, err
+			}
+			xors = xors.Xor(e)
+		}
+	}
+
+	dk := &DecryptionKey{L: L}
+	if _, err := rand.Read(dk.r[:]); err != nil {
+		return nil, err
+	}
+	if beta {
+		dk.d = digest(dk.r, xors)
+		return dk, nil
+	}
+	if _, err := rand.Read(dk.d[:]); err != nil {
+		return nil, err
+	}
+	return dk, nil
+}
+
+// Set 保存 Setup 阶段生成的密文
+func (s *Server) Set(label, enc Block) {
+	s.EDB[label] = enc
+}
+
+// ApplyUpd 将更新令牌应用到服务器
+func (s *Server) ApplyUpd(utok *UpdateToken) {
+	for l, v := range utok.Tok {
+		if utok.Op == Add {
+			s.EDB[l] = v
+		} else {
+			s.EDB[l] = s.EDB[l].Xor(v)
+		}
+	}
+}
+
+// Query 判断服务器上的映射是否满足查询密钥对应的条件。
+// 不存在的 label 按全 0 密文参与异或，因此稀疏存储时缺失的条目永远无法匹配 v = 1。
+func (s *Server) Query(dk *DecryptionKey) bool {
+	var xors Block
+	for _, l := range dk.L {
+		xors = xors.Xor(s.EDB[l])
+	}
+	return digest(dk.r, xors) == dk.d
+}
+
+// Xor 返回 b xor o
+func (b Block) Xor(o Block) Block {
+	for i := range b {
+		b[i] ^= o[i]
+	}
+	return b
+}
+
+// digest 计算 H(r || x)
+func digest(r, x Block) [sha256.Size]byte {
+	var buf [32]byte
+	copy(buf[:16], r[:])
+	copy(buf[16:], x[:])
+	return sha256.Sum256(buf[:])
+}
@@ -0,0 +1,189 @@
+package auhme
+
+import "testing"
+
+func setup(t *testing.T, m map[string]int) (*Client, *Server) {
+	client, err := KeyGen(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer()
+	for k, v := range m {
+		label, enc, err := client.Encrypt(k, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Set(label, enc)
+	}
+	return client, server
+}
+
+func TestQuery(t *testing.T) {
+	client, server := setup(t, map[string]int{"w1id1": 1, "w2id1": 1, "w3id1": 0})
+
+	tests := []struct {
+		query map[string]int
+		want  bool
+	}{
+		{map[string]int{"w1id1": 1}, true},
+		{map[string]int{"w1id1": 1, "w2id1": 1}, true},
+		{map[string]int{"w1id1": 1, "w3id1": 1}, false},
+		{map[string]int{"w3id1": 0}, true},
+	}
+	for _, tt := range tests {
+		dk, err := client.GenKey(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := server.Query(dk); got != tt.want {
+			t.Errorf("Query(%v) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAddUpdate(t *testing.T) {
+	client, server := setup(t, map[string]int{"w1id1": 1})
+
+	utok, err := client.GenUpd(Add, "w1id2", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ApplyUpd(utok)
+
+	dk, err := client.GenKey(map[string]int{"w1id1": 1, "w1id2": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Query(dk) {
+		t.Error("Query after Add update = false, want true")
+	}
+}
+
+func TestEditUpdate(t *testing.T) {
+	for _, delta := range []int{0, 4} {
+		client, server := setup(t, map[string]int{"w1id1": 1, "w2id1": 0, "w3id1": 1})
+		client.delta = delta
+
+		edits := []struct {
+			k string
+			v int
+		}{{"w2id1", 1}, {"w3id1", 0}}
+		for _, e := range edits {
+			utok, err := client.GenUpd(Edit, e.k, e.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if utok != nil {
+				server.ApplyUpd(utok)
+			}
+		}
+
+		tests := []struct {
+			query map[string]int
+			want  bool
+		}{
+			{map[string]int{"w1id1": 1, "w2id1": 1}, true},
+			{map[string]int{"w3id1": 1}, false},
+			{map[string]int{"w3id1": 0}, true},
+		}
+		for _, tt := range tests {
+			dk, err := client.GenKey(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := server.Query(dk); got != tt.want {
+				t.Errorf("delta=%d: Query(%v) = %v, want %v", delta, tt.query, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestStateRestore(t *testing.T) {
+	client, server := setup(t, map[string]int{"w1id1": 1, "w2id1": 0})
+	client.delta = 4
+	if _, err := client.GenUpd(Edit, "w2id1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewClient(client.Keys, 0)
+	restored.Restore(client.State())
+
+	dk, err := restored.GenKey(map[string]int{"w1id1": 1, "w2id1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Query(dk) {
+		t.Error("Query with restored client = false, want true")
+	}
+}
+
+func TestSparseQuery(t *testing.T) {
+	client, server := setup(t, map[string]int{"w1id1": 1})
+	client.delta = 0
+	for i := 0; i < 3; i++ {
+		l, enc, err := client.Dummy()
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Set(l, enc)
+	}
+
+	dk, err := client.GenKey(map[string]int{"w1id1": 1, "w2id1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Query(dk) {
+		t.Error("Query over absent label = true, want false")
+	}
+
+	utok, err := client.GenUpd(Add, "w2id1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ApplyUpd(utok)
+	dk, err = client.GenKey(map[string]int{"w1id1": 1, "w2id1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Query(dk) {
+		t.Error("Query after adding absent label = false, want true")
+	}
+
+	utok, err = client.GenUpd(Edit, "w1id1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.ApplyUpd(utok)
+	dk, err = client.GenKey(map[string]int{"w2id1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Query(dk) {
+		t.Error("Query after evicting dummies = false, want true")
+	}
+}
+
+func TestEditUnchangedValue(t *testing.T) {
+	client, server := setup(t, map[string]int{"w1id1": 0, "w2id1": 1})
+	client.delta = 0
+	for _, e := range []struct {
+		k string
+		v int
+	}{{"w1id1", 0}, {"w2id1", 1}, {"w2id1", 0}, {"w2id1", 1}} {
+		utok, err := client.GenUpd(Edit, e.k, e.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if utok != nil {
+			server.ApplyUpd(utok)
+		}
+	}
+
+	dk, err := client.GenKey(map[string]int{"w1id1": 0, "w2id1": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !server.Query(dk) {
+		t.Error("Query after unchanged edits = false, want true")
+	}
+}
@@ -0,0 +1,141 @@
+package leakage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Event 服务器在一次查询或更新中观察到的信息，写入 JSON-lines 文件的一行
+type Event struct {
+	QueryID int    `json:"query_id"`
+	Scheme  string `json:"scheme"`
+	Type    string `json:"type"` // search 或 update
+	// Addresses 为查询访问或更新写入的地址（ODXT stoken、Mitra 地址）
+	Addresses []string `json:"addresses,omitempty"`
+	// Found 为 Addresses 中返回了密文的位置
+	Found []int `json:"found,omitempty"`
+	// XtagHits 为 ODXT 每个 s-term 条目的 xtag 命中数
+	XtagHits []int `json:"xtag_hits,omitempty"`
+	// AuhmeQueries 为 AUHME 每个解密密钥访问的标签及结果
+	AuhmeQueries []AuhmeQuery `json:"auhme_queries,omitempty"`
+	// Positions 为 AUHME 查询返回的位置
+	Positions []int `json:"positions,omitempty"`
+	// AuhmeLabels 为更新写入的 AUHME 标签
+	AuhmeLabels []string `json:"auhme_labels,omitempty"`
+	// Token 为非前向安全方案中服务器收到的可重复使用的地址令牌 ka_w
+	Token string `json:"token,omitempty"`
+	// Results 为查询后客户端从服务器取回的文档句柄（访问模式），由调用方记录
+	Results []string `json:"results,omitempty"`
+}
+
+// AuhmeQuery 一次 AUHME 查询访问的标签及是否匹配
+type AuhmeQuery struct {
+	Labels []string `json:"labels"`
+	Match  bool     `json:"match"`
+}
+
+// Recorder 记录服务器端观察到的泄露。方法在 nil 接收者上为空操作，未启用时无需判断。
+// Begin 和 End 可以嵌套，嵌套的调用记录到同一个事件中，最外层 End 时写出。
+type Recorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	nextID  int
+	depth   int
+	current *Event
+}
+
+// NewRecorder 创建写入 path 的 Recorder
+func NewRecorder(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := bufio.NewWriter(file)
+	return &Recorder{file: file, w: w, enc: json.NewEncoder(w), nextID: 1}, nil
+}
+
+// Begin 开始一个事件，typ 为 search 或 update
+func (r *Recorder) Begin(scheme, typ string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.depth == 0 {
+		r.current = &Event{QueryID: r.nextID, Scheme: scheme, Type: typ}
+		r.nextID++
+	}
+	r.depth++
+}
+
+// Record 修改当前事件，不在事件中时忽略
+func (r *Recorder) Record(f func(e *Event)) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil {
+		f(r.current)
+	}
+}
+
+// End 结束事件，最外层时写出一行
+func (r *Recorder) End() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.depth == 0 {
+		return nil
+	}
+	r.depth--
+	if r.depth > 0 {
+		return nil
+	}
+	e := r.current
+	r.current = nil
+	return r.enc.Encode(e)
+}
+
+// Close 写出缓冲并关闭文件
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+// ReadEvents 读取 Recorder 写出的 JSON-lines 文件
+func ReadEvents(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := make([]Event, 0)
+	dec := json.NewDecoder(file)
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,59 @@
+package leakage
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Leakage", "test.jsonl")
+	r, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 嵌套的事件合并为一行
+	r.Begin("HDXT", "search")
+	r.Begin("HDXT", "search")
+	r.Record(func(e *Event) { e.Addresses = append(e.Addresses, "t1", "t2") })
+	if err := r.End(); err != nil {
+		t.Fatal(err)
+	}
+	r.Record(func(e *Event) { e.Positions = append(e.Positions, 1) })
+	if err := r.End(); err != nil {
+		t.Fatal(err)
+	}
+	// 不在事件中时 Record 被忽略
+	r.Record(func(e *Event) { e.Found = append(e.Found, 0) })
+	r.Begin("ODXT", "update")
+	r.Record(func(e *Event) { e.Addresses = append(e.Addresses, "a") })
+	if err := r.End(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ReadEvents(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if e := events[0]; e.QueryID != 1 || e.Type != "search" || !slices.Equal(e.Addresses, []string{"t1", "t2"}) || !slices.Equal(e.Positions, []int{1}) || e.Found != nil {
+		t.Errorf("events[0] = %+v", e)
+	}
+	if e := events[1]; e.QueryID != 2 || e.Scheme != "ODXT" || e.Type != "update" {
+		t.Errorf("events[1] = %+v", e)
+	}
+
+	// nil Recorder 上的调用为空操作
+	var nilRecorder *Recorder
+	nilRecorder.Begin("ODXT", "search")
+	nilRecorder.Record(func(e *Event) { t.Error("Record called on nil Recorder") })
+	if err := nilRecorder.End(); err != nil {
+		t.Error(err)
+	}
+}
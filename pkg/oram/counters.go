@@ -0,0 +1,46 @@
+package oram
+
+import "errors"
+
+// counterValueSize CounterStore 中值的最大长度，首字节保存实际长度
+const counterValueSize = 64
+
+// CounterStore 将 Map 适配为 ODXT.CounterStore，服务器只能看到固定数量的随机路径访问
+type CounterStore struct {
+	*Map
+}
+
+// NewCounterStore 创建最多保存 capacity 个计数的 CounterStore
+func NewCounterStore(store BucketStore, key []byte, capacity int) (*CounterStore, error) {
+	m, err := NewMap(store, key, capacity, counterValueSize)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterStore{m}, nil
+}
+
+func (s *CounterStore) Get(labels []string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(labels))
+	for _, label := range labels {
+		v, ok, err := s.Map.Get(label)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result[label] = v[1 : 1+int(v[0])]
+		}
+	}
+	return result, nil
+}
+
+func (s *CounterStore) Put(counters map[string][]byte) error {
+	for label, c := range counters {
+		if len(c) >= counterValueSize {
+			return errors.New("counter too long")
+		}
+		if err := s.Map.Put(label, append([]byte{byte(len(c))}, c...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package oram
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrFull 插入的键数超过 Map 的容量
+var ErrFull = errors.New("oblivious map is full")
+
+// Map 基于 Path ORAM 的不经意映射（参考 Wang 等人的 oblivious data structures）。
+// 键值对保存在 AVL 树的节点中，每个节点是一个 ORAM 块，父节点的指针携带子节点的叶子，
+// 因此客户端只需保存根指针。每次操作从根开始取出路径上的节点，在客户端完成查找、插入和旋转后，
+// 为所有取出的节点重新分配随机叶子并放回 ORAM。每次操作的路径访问数补齐到 AVL 树高度的上界，
+// 服务器只能看到固定数量的随机路径，无法得知访问的是哪个键。
+type Map struct {
+	ORAM      *ORAM
+	root      ptr
+	nextID    uint64
+	size      int
+	capacity  int
+	valueSize int
+	// maxAccesses 为每次操作的路径访问数
+	maxAccesses int
+	// cache 和 fetches 为本次操作取出的节点及路径访问数，orig 为节点取出时的副本
+	cache   map[uint64]*node
+	orig    map[uint64]*node
+	fetches int
+}
+
+type ptr struct {
+	ID     uint64
+	Leaf   uint64
+	Height int32
+}
+
+type node struct {
+	Key   [32]byte
+	Value []byte
+	Left  ptr
+	Right ptr
+}
+
+func (n *node) height() int32 {
+	return 1 + max(n.Left.Height, n.Right.Height)
+}
+
+// NewMap 创建最多保存 capacity 个键、值长度为 valueSize 字节的 Map。
+// 根指针和 nextID 只保存在内存中，Map 的内容在进程退出后无法恢复
+func NewMap(store BucketStore, key []byte, capacity, valueSize int) (*Map, error) {
+	o, err := New(store, key, capacity, 32+valueSize+2*20)
+	if err != nil {
+		return nil, err
+	}
+	return &Map{
+		ORAM:        o,
+		nextID:      1,
+		capacity:    capacity,
+		valueSize:   valueSize,
+		maxAccesses: int(math.Ceil(1.4405*math.Log2(float64(capacity)+2))) + 1,
+	}, nil
+}
+
+// Len 返回键的数量
+func (m *Map) Len() int {
+	return m.size
+}
+
+// Get 返回 key 对应的值
+func (m *Map) Get(key string) ([]byte, bool, error) {
+	k := sha256.Sum256([]byte(key))
+	m.begin()
+	var value []byte
+	p := m.root
+	for p.ID != 0 {
+		n, err := m.fetch(p)
+		if err != nil {
+			// 已取出的节点未被修改，仍需放回 ORAM
+			return nil, false, errors.Join(err, m.finish())
+		}
+		switch c := bytes.Compare(k[:], n.Key[:]); {
+		case c < 0:
+			p = n.Left
+		case c > 0:
+			p = n.Right
+		default:
+			value = append([]byte(nil), n.Value...)
+			p = ptr{}
+		}
+	}
+	if err := m.finish(); err != nil {
+		return nil, false, err
+	}
+	return value, value != nil, nil
+}
+
+// Put 写入或覆盖 key 对应的值，value 不足 valueSize 时以 0 补齐
+func (m *Map) Put(key string, value []byte) error {
+	if len(value) > m.valueSize {
+		return errors.New("value too long")
+	}
+	v := make([]byte, m.valueSize)
+	copy(v, value)
+	k := sha256.Sum256([]byte(key))
+	nextID, size := m.nextID, m.size
+	m.begin()
+	root, err := m.insert(m.root, k, v)
+	if err != nil {
+		// 插入失败（包括 ErrFull）时丢弃修改，将取出时的节点放回 ORAM，否则路径上的节点会丢失
+		m.nextID, m.size = nextID, size
+		m.cache = m.orig
+		return errors.Join(err, m.finish())
+	}
+	m.root = root
+	return m.finish()
+}
+
+// GetInt 读取以 8 字节大端整数保存的值，不存在时为 0
+func (m *Map) GetInt(key string) (int, error) {
+	v, ok, err := m.Get(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint64(v)), nil
+}
+
+// PutInt 以 8 字节大端整数保存值
+func (m *Map) PutInt(key string, v int) error {
+	return m.Put(key, binary.BigEndian.AppendUint64(nil, uint64(v)))
+}
+
+// begin 开始一次操作
+func (m *Map) begin() {
+	m.cache, m.orig, m.fetches = make(map[uint64]*node), make(map[uint64]*node), 0
+}
+
+// fetch 从 ORAM 中取出指针 p 指向的节点，已取出的节点直接返回缓存
+func (m *Map) fetch(p ptr) (*node, error) {
+	if n, ok := m.cache[p.ID]; ok {
+		return n, nil
+	}
+	data, err := m.ORAM.ReadRemove(p.ID, p.Leaf)
+	if err != nil {
+		return nil, err
+	}
+	m.fetches++
+	n := m.decode(data)
+	m.cache[p.ID] = n
+	m.orig[p.ID] = m.decode(data)
+	return n, nil
+}
+
+func (m *Map) insert(p ptr, k [32]byte, v []byte) (ptr, error) {
+	if p.ID == 0 {
+		if m.size >= m.capacity {
+			return ptr{}, ErrFull
+		}
+		id := m.nextID
+		m.nextID++
+		m.size++
+		m.cache[id] = &node{Key: k, Value: v}
+		return ptr{ID: id, Height: 1}, nil
+	}
+	n, err := m.fetch(p)
+	if err != nil {
+		return ptr{}, err
+	}
+	switch c := bytes.Compare(k[:], n.Key[:]); {
+	case c < 0:
+		if n.Left, err = m.insert(n.Left, k, v); err != nil {
+			return ptr{}, err
+		}
+	case c > 0:
+		if n.Right, err = m.insert(n.Right, k, v); err != nil {
+			return ptr{}, err
+		}
+	default:
+		n.Value = v
+		return p, nil
+	}
+	return m.balance(p.ID)
+}
+
+// balance 在节点 id 处恢复 AVL 平衡，返回子树的新根
+func (m *Map) balance(id uint64) (ptr, error) {
+	n := m.cache[id]
+	switch bf := n.Left.Height - n.Right.Height; {
+	case bf > 1:
+		l, err := m.fetch(n.Left)
+		if err != nil {
+			return ptr{}, err
+		}
+		if l.Left.Height < l.Right.Height {
+			if n.Left, err = m.rotateLeft(n.Left.ID); err != nil {
+				return ptr{}, err
+			}
+		}
+		return m.rotateRight(id)
+	case bf < -1:
+		r, err := m.fetch(n.Right)
+		if err != nil {
+			return ptr{}, err
+		}
+		if r.Right.Height < r.Left.Height {
+			if n.Right, err = m.rotateRight(n.Right.ID); err != nil {
+				return ptr{}, err
+			}
+		}
+		return m.rotateLeft(id)
+	}
+	return ptr{ID: id, Height: n.height()}, nil
+}
+
+func (m *Map) rotateRight(id uint64) (ptr, error) {
+	n := m.cache[id]
+	l, err := m.fetch(n.Left)
+	if err != nil {
+		return ptr{}, err
+	}
+	lp := n.Left
+	n.Left = l.Right
+	l.Right = ptr{ID: id, Height: n.height()}
+	return ptr{ID: lp.ID, Height: l.height()}, nil
+}
+
+func (m *Map) rotateLeft(id uint64) (ptr, error) {
+	n := m.cache[id]
+	r, err := m.fetch(n.Right)
+	if err != nil {
+		return ptr{}, err
+	}
+	rp := n.Right
+	n.Right = r.Left
+	r.Left = ptr{ID: id, Height: n.height()}
+	return ptr{ID: rp.ID, Height: r.height()}, nil
+}
+
+// finish 补齐哑访问，为取出的节点分配新叶子、更新指针并放回 ORAM
+func (m *Map) finish() error {
+	for i := m.fetches; i < m.maxAccesses; i++ {
+		leaf, err := m.ORAM.RandomLeaf()
+		if err != nil {
+			return err
+		}
+		if _, err := m.ORAM.ReadRemove(0, leaf); err != nil {
+			return err
+		}
+	}
+
+	leaves := make(map[uint64]uint64, len(m.cache))
+	for id := range m.cache {
+		leaf, err := m.ORAM.RandomLeaf()
+		if err != nil {
+			return err
+		}
+		leaves[id] = leaf
+	}
+	relink := func(p *ptr) {
+		if leaf, ok := leaves[p.ID]; ok {
+			p.Leaf = leaf
+		}
+	}
+	relink(&m.root)
+	for id, n := range m.cache {
+		relink(&n.Left)
+		relink(&n.Right)
+		if err := m.ORAM.Add(id, leaves[id], m.encode(n)); err != nil {
+			return err
+		}
+	}
+	m.cache, m.orig = nil, nil
+	return nil
+}
+
+// encode 将节点编码为 key||value||left||right，指针为 id||leaf||height
+func (m *Map) encode(n *node) []byte {
+	data := make([]byte, 0, 32+m.valueSize+40)
+	data = append(data, n.Key[:]...)
+	data = append(data, n.Value...)
+	for _, p := range []ptr{n.Left, n.Right} {
+		data = binary.BigEndian.AppendUint64(data, p.ID)
+		data = binary.BigEndian.AppendUint64(data, p.Leaf)
+		data = binary.BigEndian.AppendUint32(data, uint32(p.Height))
+	}
+	return data
+}
+
+func (m *Map) decode(data []byte) *node {
+	n := &node{Value: append([]byte(nil), data[32:32+m.valueSize]...)}
+	copy(n.Key[:], data[:32])
+	rest := data[32+m.valueSize:]
+	for _, p := range []*ptr{&n.Left, &n.Right} {
+		p.ID = binary.BigEndian.Uint64(rest)
+		p.Leaf = binary.BigEndian.Uint64(rest[8:])
+		p.Height = int32(binary.BigEndian.Uint32(rest[16:]))
+		rest = rest[20:]
+	}
+	return n
+}
@@ -0,0 +1,116 @@
+package oram
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var _ ODXT.CounterStore = (*CounterStore)(nil)
+
+func TestMap(t *testing.T) {
+	store := NewMemoryBucketStore()
+	m, err := NewMap(store, []byte("0123456789abcdef"), 256, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		if err := m.PutInt(fmt.Sprintf("w%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i += 3 {
+		if err := m.PutInt(fmt.Sprintf("w%d", i), i*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		want := i
+		if i%3 == 0 {
+			want = i * 10
+		}
+		got, err := m.GetInt(fmt.Sprintf("w%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("GetInt(w%d) = %d, want %d", i, got, want)
+		}
+	}
+	if got, err := m.GetInt("missing"); err != nil || got != 0 {
+		t.Errorf("GetInt(missing) = %d, %v, want 0", got, err)
+	}
+	if m.Len() != n {
+		t.Errorf("Len() = %d, want %d", m.Len(), n)
+	}
+
+	// 每次操作访问相同数量的路径
+	before := m.ORAM.Accesses
+	if _, err := m.GetInt("w1"); err != nil {
+		t.Fatal(err)
+	}
+	get := m.ORAM.Accesses - before
+	before = m.ORAM.Accesses
+	if err := m.PutInt("new", 1); err != nil {
+		t.Fatal(err)
+	}
+	if put := m.ORAM.Accesses - before; get != m.maxAccesses || put != m.maxAccesses {
+		t.Errorf("accesses per Get/Put = %d/%d, want %d", get, put, m.maxAccesses)
+	}
+	if m.ORAM.StashSize() > 40 {
+		t.Errorf("stash size %d", m.ORAM.StashSize())
+	}
+}
+
+func TestMapFull(t *testing.T) {
+	m, err := NewMap(NewMemoryBucketStore(), []byte("0123456789abcdef"), 16, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		if err := m.PutInt(fmt.Sprintf("w%d", i), i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if err := m.PutInt(fmt.Sprintf("new%d", i), 1); !errors.Is(err, ErrFull) {
+			t.Fatalf("PutInt on full map error = %v, want %v", err, ErrFull)
+		}
+	}
+	// 已有的键仍可覆盖和读取
+	if err := m.PutInt("w0", 100); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 16; i++ {
+		want := i
+		if i == 0 {
+			want = 100
+		}
+		if got, err := m.GetInt(fmt.Sprintf("w%d", i)); err != nil || got != want {
+			t.Errorf("GetInt(w%d) = %d, %v, want %d", i, got, err, want)
+		}
+	}
+	if m.Len() != 16 {
+		t.Errorf("Len() = %d, want 16", m.Len())
+	}
+}
+
+func TestCounterStore(t *testing.T) {
+	s, err := NewCounterStore(NewMemoryBucketStore(), []byte("0123456789abcdef"), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(map[string][]byte{"a": []byte("xyz"), "b": []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || string(got["a"]) != "xyz" || string(got["b"]) != "1" {
+		t.Errorf("Get = %q", got)
+	}
+}
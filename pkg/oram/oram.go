@@ -0,0 +1,210 @@
+package oram
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Z 每个桶中的块数
+const Z = 4
+
+// ORAM Path ORAM 客户端。服务器端以完全二叉树保存 2^(L+1)-1 个加密桶，
+// 每个块映射到一个叶子，并始终位于根到该叶子的路径上。
+// 位置映射不由 ORAM 保存，调用方（如 Map）在指针中携带块的叶子。
+type ORAM struct {
+	store     BucketStore
+	aead      cipher.AEAD
+	height    int // 叶子所在层，叶子数为 1<<height
+	blockSize int
+	stash     map[uint64]*block
+	// Accesses 为累计读写的路径数
+	Accesses int
+}
+
+type block struct {
+	id   uint64 // 0 表示空位
+	leaf uint64
+	data []byte
+}
+
+// New 创建容量至少为 capacity 个块、每块 blockSize 字节的 ORAM，并在 store 中写入全部空桶。
+// stash 只保存在内存中，store 中已有的桶会被覆盖，因此 ORAM 不能跨进程重新打开
+func New(store BucketStore, key []byte, capacity, blockSize int) (*ORAM, error) {
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(c)
+	if err != nil {
+		return nil, err
+	}
+	height := 0
+	for 1<<height < capacity {
+		height++
+	}
+	o := &ORAM{store: store, aead: aead, height: height, blockSize: blockSize, stash: make(map[uint64]*block)}
+
+	// 初始化所有桶，使服务器无法区分空桶和真实桶
+	n := 1<<(height+1) - 1
+	idxs := make([]int, 0, 1024)
+	buckets := make([][]byte, 0, 1024)
+	for i := 0; i < n; i++ {
+		bucket, err := o.encryptBucket(nil)
+		if err != nil {
+			return nil, err
+		}
+		idxs, buckets = append(idxs, i), append(buckets, bucket)
+		if len(idxs) == cap(idxs) || i == n-1 {
+			if err := store.WritePath(idxs, buckets); err != nil {
+				return nil, err
+			}
+			idxs, buckets = idxs[:0], buckets[:0]
+		}
+	}
+	return o, nil
+}
+
+// Leaves 返回叶子数
+func (o *ORAM) Leaves() uint64 {
+	return 1 << o.height
+}
+
+// RandomLeaf 返回均匀随机的叶子
+func (o *ORAM) RandomLeaf() (uint64, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(b[:]) % o.Leaves(), nil
+}
+
+// StashSize 返回客户端暂存区中的块数
+func (o *ORAM) StashSize() int {
+	return len(o.stash)
+}
+
+// path 返回从根到叶子 leaf 的桶编号
+func (o *ORAM) path(leaf uint64) []int {
+	idxs := make([]int, o.height+1)
+	i := int(leaf) + 1<<o.height - 1
+	for d := o.height; d >= 0; d-- {
+		idxs[d] = i
+		i = (i - 1) / 2
+	}
+	return idxs
+}
+
+// ReadRemove 读取叶子 leaf 的路径，取出块 id 并从 ORAM 中移除，随后写回路径。
+// id 为 0 时为一次哑访问，只读写路径。
+func (o *ORAM) ReadRemove(id, leaf uint64) ([]byte, error) {
+	idxs := o.path(leaf)
+	buckets, err := o.store.ReadPath(idxs)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets {
+		blocks, err := o.decryptBucket(bucket)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range blocks {
+			o.stash[b.id] = b
+		}
+	}
+
+	var data []byte
+	if id != 0 {
+		b, ok := o.stash[id]
+		if !ok {
+			return nil, fmt.Errorf("block %d not found on path %d", id, leaf)
+		}
+		data = b.data
+		delete(o.stash, id)
+	}
+
+	if err := o.evict(leaf, idxs); err != nil {
+		return nil, err
+	}
+	o.Accesses++
+	return data, nil
+}
+
+// Add 将块 id 以叶子 leaf 放入暂存区，在之后的访问中被逐出到树中
+func (o *ORAM) Add(id, leaf uint64, data []byte) error {
+	if id == 0 {
+		return errors.New("block id 0 is reserved")
+	}
+	if len(data) != o.blockSize {
+		return fmt.Errorf("block size %d, want %d", len(data), o.blockSize)
+	}
+	o.stash[id] = &block{id: id, leaf: leaf, data: data}
+	return nil
+}
+
+// evict 从叶子到根贪心地将暂存区中的块写入路径上的桶
+func (o *ORAM) evict(leaf uint64, idxs []int) error {
+	out := make([][]byte, len(idxs))
+	for d := o.height; d >= 0; d-- {
+		shift := uint(o.height - d)
+		chosen := make([]*block, 0, Z)
+		for id, b := range o.stash {
+			if len(chosen) == Z {
+				break
+			}
+			if b.leaf>>shift == leaf>>shift {
+				chosen = append(chosen, b)
+				delete(o.stash, id)
+			}
+		}
+		bucket, err := o.encryptBucket(chosen)
+		if err != nil {
+			return err
+		}
+		out[d] = bucket
+	}
+	return o.store.WritePath(idxs, out)
+}
+
+// encryptBucket 将最多 Z 个块补齐为 Z 个后加密，格式为 nonce||AES-GCM(id||leaf||data ...)
+func (o *ORAM) encryptBucket(blocks []*block) ([]byte, error) {
+	slot := 16 + o.blockSize
+	plain := make([]byte, Z*slot)
+	for i, b := range blocks {
+		binary.BigEndian.PutUint64(plain[i*slot:], b.id)
+		binary.BigEndian.PutUint64(plain[i*slot+8:], b.leaf)
+		copy(plain[i*slot+16:(i+1)*slot], b.data)
+	}
+	nonce := make([]byte, o.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return o.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (o *ORAM) decryptBucket(bucket []byte) ([]*block, error) {
+	if len(bucket) < o.aead.NonceSize() {
+		return nil, errors.New("malformed bucket")
+	}
+	plain, err := o.aead.Open(nil, bucket[:o.aead.NonceSize()], bucket[o.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	slot := 16 + o.blockSize
+	blocks := make([]*block, 0, Z)
+	for i := 0; i < Z; i++ {
+		id := binary.BigEndian.Uint64(plain[i*slot:])
+		if id == 0 {
+			continue
+		}
+		blocks = append(blocks, &block{
+			id:   id,
+			leaf: binary.BigEndian.Uint64(plain[i*slot+8:]),
+			data: plain[i*slot+16 : (i+1)*slot],
+		})
+	}
+	return blocks, nil
+}
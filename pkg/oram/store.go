@@ -0,0 +1,92 @@
+package oram
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// BucketStore 服务器端加密桶的存储，桶按完全二叉树的层序编号
+type BucketStore interface {
+	// ReadPath 按顺序读取 idxs 对应的桶
+	ReadPath(idxs []int) ([][]byte, error)
+	// WritePath 覆盖写入 idxs 对应的桶
+	WritePath(idxs []int, buckets [][]byte) error
+}
+
+// MemoryBucketStore 在内存中保存桶
+type MemoryBucketStore map[int][]byte
+
+func NewMemoryBucketStore() MemoryBucketStore {
+	return make(MemoryBucketStore)
+}
+
+func (s MemoryBucketStore) ReadPath(idxs []int) ([][]byte, error) {
+	buckets := make([][]byte, len(idxs))
+	for i, idx := range idxs {
+		bucket, ok := s[idx]
+		if !ok {
+			return nil, fmt.Errorf("bucket %d not found", idx)
+		}
+		buckets[i] = bucket
+	}
+	return buckets, nil
+}
+
+func (s MemoryBucketStore) WritePath(idxs []int, buckets [][]byte) error {
+	for i, idx := range idxs {
+		s[idx] = buckets[i]
+	}
+	return nil
+}
+
+// MySQLBucketStore 使用 MySQL 数据表保存桶
+type MySQLBucketStore struct {
+	DB        *sql.DB
+	TableName string
+}
+
+// NewMySQLBucketStore 创建数据表 tableName 并返回桶存储
+func NewMySQLBucketStore(db *sql.DB, tableName string) (*MySQLBucketStore, error) {
+	createTableSQL := fmt.Sprintf(`
+	CREATE TABLE IF NOT EXISTS %s (
+		idx INT PRIMARY KEY,
+		bucket BLOB NOT NULL
+	);`, tableName)
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return nil, err
+	}
+	return &MySQLBucketStore{DB: db, TableName: tableName}, nil
+}
+
+func (s *MySQLBucketStore) ReadPath(idxs []int) ([][]byte, error) {
+	querySQL := "SELECT bucket FROM " + s.TableName + " WHERE idx = ?"
+	buckets := make([][]byte, len(idxs))
+	for i, idx := range idxs {
+		if err := s.DB.QueryRow(querySQL, idx).Scan(&buckets[i]); err != nil {
+			return nil, fmt.Errorf("error reading bucket %d: %v", idx, err)
+		}
+	}
+	return buckets, nil
+}
+
+func (s *MySQLBucketStore) WritePath(idxs []int, buckets [][]byte) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf("REPLACE INTO %s (idx, bucket) VALUES (?, ?)", s.TableName))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i, idx := range idxs {
+		if _, err = stmt.Exec(idx, buckets[i]); err != nil {
+			return fmt.Errorf("error writing bucket %d: %v", idx, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,67 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/BDXT"
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+)
+
+// BDXTScheme 将后向隐私的 BDXT 适配为 Scheme
+type BDXTScheme struct {
+	*BDXT.BDXT
+}
+
+// NewBDXT 使用随机密钥创建 BDXT，加密索引保存在 store 中
+func NewBDXT(store ODXT.Store) (*BDXTScheme, error) {
+	var keys [4][]byte
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		if _, err := rand.Read(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	s := &BDXTScheme{&BDXT.BDXT{}}
+	s.Init(keys, store)
+	return s, nil
+}
+
+func (s *BDXTScheme) Name() string {
+	return "BDXT"
+}
+
+func (s *BDXTScheme) Setup(dataset Dataset) error {
+	keywords, index := dataset.Inverted()
+	for _, w := range keywords {
+		if err := s.add(utils.Add, w, index[w]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BDXTScheme) Update(op utils.Operation, id string, keywords []string) error {
+	for _, w := range keywords {
+		if err := s.add(op, w, []string{id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BDXTScheme) add(op utils.Operation, keyword string, ids []string) error {
+	_, payloads, err := s.Encrypt(keyword, ids, int(op))
+	if err != nil {
+		return err
+	}
+	return s.Store.Write(payloads)
+}
+
+// Search 合并 s-term 的开销计入客户端时间
+func (s *BDXTScheme) Search(query []string) ([]string, Metrics, error) {
+	res, err := s.BDXT.Search(query, "")
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	return res.IDs, Metrics{ClientTime: res.ClientTime + res.CompactTime, ServerTime: res.ServerTime}, nil
+}
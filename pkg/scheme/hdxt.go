@@ -0,0 +1,57 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/HDXT"
+	"ConjunctiveSSE/pkg/utils"
+)
+
+// HDXTScheme 将 HDXT 适配为 Scheme
+type HDXTScheme struct {
+	*HDXT.HDXT
+}
+
+// NewHDXT 使用随机密钥创建内存中的 HDXT
+func NewHDXT(mode HDXT.SetupMode, padStep int) (*HDXTScheme, error) {
+	s := &HDXTScheme{&HDXT.HDXT{Mode: mode, PadStep: padStep}}
+	if err := s.InitClient(true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewMitraNaive 创建只使用 Mitra 并在客户端求交的朴素基线
+func NewMitraNaive() (*HDXTScheme, error) {
+	return NewHDXT(HDXT.MitraOnly, 0)
+}
+
+func (s *HDXTScheme) Name() string {
+	return s.HDXT.Name()
+}
+
+func (s *HDXTScheme) Setup(dataset Dataset) error {
+	for _, doc := range dataset {
+		if _, _, err := s.HDXT.Setup(doc.ID, doc.Keywords, int(utils.Add)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *HDXTScheme) Update(op utils.Operation, id string, keywords []string) error {
+	_, _, tokList, err := s.Encrypt(id, keywords, op)
+	if err != nil {
+		return err
+	}
+	for _, tok := range tokList {
+		s.AuhmeServer.ApplyUpd(tok)
+	}
+	return nil
+}
+
+func (s *HDXTScheme) Search(query []string) ([]string, Metrics, error) {
+	ids, times, err := s.HDXT.Search(query)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	return ids, Metrics{ClientTime: times.ClientTime(), ServerTime: times.ServerTime()}, nil
+}
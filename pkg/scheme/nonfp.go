@@ -0,0 +1,91 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/leakage"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ODXTNonFPScheme 不具备前向安全的 ODXT 基线：查询时客户端将 s-term 的地址密钥 ka_w 发送给服务器，
+// 服务器自行枚举地址 PRF(ka_w, j) 直到不存在为止。服务器保留 ka_w 后，
+// 可以将之后写入的条目与之前的查询关联，用于演示文件注入攻击；ka_w 不能解密条目的值。
+type ODXTNonFPScheme struct {
+	*ODXTScheme
+}
+
+// NewODXTNonFP 使用随机密钥创建非前向安全的 ODXT 基线，加密索引保存在 store 中
+func NewODXTNonFP(store ODXT.Store) (*ODXTNonFPScheme, error) {
+	s, err := NewODXT(store)
+	if err != nil {
+		return nil, err
+	}
+	return &ODXTNonFPScheme{s}, nil
+}
+
+func (s *ODXTNonFPScheme) Name() string {
+	return "ODXTNonFP"
+}
+
+func (s *ODXTNonFPScheme) Search(query []string) ([]string, Metrics, error) {
+	s.Recorder.Begin(s.Name(), "search")
+	defer s.Recorder.End()
+
+	start := time.Now()
+	w1, _ := ODXT.STerm(query, s.UpdateCnt)
+	kw, err := s.KeywordKey(w1)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	_, _, xtokenList, err := s.Trapdoor(query)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	clientTime := time.Since(start)
+
+	// 服务器由令牌枚举 s-term 的地址，条目数应与客户端的计数一致
+	start = time.Now()
+	addresses, err := s.enumerate(kw.A)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	if len(addresses) != len(xtokenList) {
+		return nil, Metrics{}, fmt.Errorf("server enumerated %d entries for %q, client expects %d", len(addresses), w1, len(xtokenList))
+	}
+	enumerateTime := time.Since(start)
+	s.Recorder.Record(func(e *leakage.Event) {
+		e.Token = base64.StdEncoding.EncodeToString(kw.A)
+	})
+	serverTime, sEOpList, err := s.ServerSearch(addresses, xtokenList, "")
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+
+	start = time.Now()
+	ids, err := s.Decrypt(query, sEOpList)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	return ids, Metrics{ClientTime: clientTime + time.Since(start), ServerTime: enumerateTime + serverTime}, nil
+}
+
+// enumerate 服务器端按 j = 1, 2, ... 计算地址，直到地址不在加密索引中，其他存储错误直接返回
+func (s *ODXTNonFPScheme) enumerate(t []byte) ([]string, error) {
+	addresses := make([]string, 0)
+	for j := 1; ; j++ {
+		address, err := ODXT.TokenAddress(t, j)
+		if err != nil {
+			return nil, err
+		}
+		_, err = s.Store.Search([]string{address})
+		if errors.Is(err, ODXT.ErrNotFound) {
+			return addresses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+}
@@ -0,0 +1,72 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+	"time"
+)
+
+// ODXTScheme 将 ODXT 适配为 Scheme
+type ODXTScheme struct {
+	*ODXT.ODXT
+}
+
+// NewODXT 使用随机密钥创建 ODXT，加密索引保存在 store 中
+func NewODXT(store ODXT.Store) (*ODXTScheme, error) {
+	var keys [4][]byte
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		if _, err := rand.Read(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	s := &ODXTScheme{&ODXT.ODXT{}}
+	s.Init(keys, store)
+	return s, nil
+}
+
+func (s *ODXTScheme) Name() string {
+	return "ODXT"
+}
+
+func (s *ODXTScheme) Setup(dataset Dataset) error {
+	keywords, index := dataset.Inverted()
+	for _, w := range keywords {
+		if err := s.add(utils.Add, w, index[w]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ODXTScheme) Update(op utils.Operation, id string, keywords []string) error {
+	for _, w := range keywords {
+		if err := s.add(op, w, []string{id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ODXTScheme) add(op utils.Operation, keyword string, ids []string) error {
+	_, payloads, err := s.Encrypt(keyword, ids, int(op))
+	if err != nil {
+		return err
+	}
+	return s.Store.Write(payloads)
+}
+
+func (s *ODXTScheme) Search(query []string) ([]string, Metrics, error) {
+	trapdoorTime, serverTime, sEOpList, err := s.ODXT.Search(query, "")
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+
+	start := time.Now()
+	ids, err := s.Decrypt(query, sEOpList)
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	return ids, Metrics{ClientTime: trapdoorTime + time.Since(start), ServerTime: serverTime}, nil
+}
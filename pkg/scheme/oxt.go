@@ -0,0 +1,57 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/OXT"
+	"ConjunctiveSSE/pkg/utils"
+	"crypto/rand"
+)
+
+// OXTScheme 将静态 OXT 适配为 Scheme，Update 始终返回 ErrStatic
+type OXTScheme struct {
+	*OXT.OXT
+}
+
+// NewOXT 使用随机密钥创建 OXT，TSet 保存在 store 中
+func NewOXT(store ODXT.Store) (*OXTScheme, error) {
+	var keys [5][]byte
+	for i := range keys {
+		keys[i] = make([]byte, 32)
+		if _, err := rand.Read(keys[i]); err != nil {
+			return nil, err
+		}
+	}
+	s := &OXTScheme{&OXT.OXT{}}
+	s.Init(keys, store)
+	return s, nil
+}
+
+func (s *OXTScheme) Name() string {
+	return "OXT"
+}
+
+func (s *OXTScheme) Setup(dataset Dataset) error {
+	keywords, index := dataset.Inverted()
+	for _, w := range keywords {
+		_, tuples, err := s.Encrypt(w, index[w])
+		if err != nil {
+			return err
+		}
+		if err := s.Store.Write(tuples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OXTScheme) Update(op utils.Operation, id string, keywords []string) error {
+	return ErrStatic
+}
+
+func (s *OXTScheme) Search(query []string) ([]string, Metrics, error) {
+	clientTime, serverTime, ids, err := s.OXT.Search(query, "")
+	if err != nil {
+		return nil, Metrics{}, err
+	}
+	return ids, Metrics{ClientTime: clientTime, ServerTime: serverTime}, nil
+}
@@ -0,0 +1,105 @@
+// Package scheme 定义各连接查询 SSE 方案的统一接口，
+// 攻击模拟、文件注入等在内存数据集上运行所有方案的工具和跨方案测试只需针对 Scheme 编写一次。
+// cmd/ODXT、cmd/HDXT、cmd/OXT 和 cmd/BDXT 的基准程序仍直接使用各方案的具体类型，
+// 因为它们读写 MySQL/MongoDB 中的状态，并输出各方案特有的开销列。
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrStatic 静态方案不支持更新
+var ErrStatic = errors.New("scheme is static and does not support updates")
+
+// Scheme 连接查询 SSE 方案
+type Scheme interface {
+	// Name 返回方案名称，用作结果目录名
+	Name() string
+	// Setup 使用数据集建立加密索引
+	Setup(dataset Dataset) error
+	// Update 对文档 id 的 keywords 执行添加或删除，静态方案返回 ErrStatic
+	Update(op utils.Operation, id string, keywords []string) error
+	// Search 执行连接查询，返回匹配的文档 id 和开销
+	Search(query []string) ([]string, Metrics, error)
+}
+
+// Metrics 一次查询的开销
+type Metrics struct {
+	ClientTime time.Duration
+	ServerTime time.Duration
+}
+
+// Document 数据集中的一个文档
+type Document struct {
+	ID       string
+	Keywords []string
+}
+
+// Dataset 文档集合
+type Dataset []Document
+
+// Inverted 返回按字典序排列的关键字及倒排索引 keyword -> ids
+func (d Dataset) Inverted() ([]string, map[string][]string) {
+	index := make(map[string][]string)
+	for _, doc := range d {
+		for _, w := range doc.Keywords {
+			index[w] = append(index[w], doc.ID)
+		}
+	}
+	keywords := make([]string, 0, len(index))
+	for w := range index {
+		keywords = append(keywords, w)
+	}
+	sort.Strings(keywords)
+	return keywords, index
+}
+
+// LoadDataset 从 MongoDB 的 id_keywords 集合读取数据集
+func LoadDataset(plaintextDB *mongo.Database) (Dataset, error) {
+	collection := plaintextDB.Collection("id_keywords")
+
+	ctx := context.TODO()
+	opts := options.Find().SetNoCursorTimeout(true).SetBatchSize(1000)
+	cur, err := collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var idKeywords []bson.M
+	if err = cur.All(ctx, &idKeywords); err != nil {
+		return nil, err
+	}
+
+	dataset := make(Dataset, 0, len(idKeywords))
+	for _, idKeyword := range idKeywords {
+		valSet, ok := idKeyword["val_set"].(primitive.A)
+		if !ok {
+			return nil, fmt.Errorf("val_set is not of type primitive.A")
+		}
+		keywords := make([]string, 0, len(valSet))
+		for _, v := range valSet {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("val_set contains non-string value")
+			}
+			keywords = append(keywords, str)
+		}
+		id, ok := idKeyword["k"].(string)
+		if !ok {
+			return nil, fmt.Errorf("k is not of type string")
+		}
+		dataset = append(dataset, Document{ID: id, Keywords: utils.RemoveDuplicates(keywords)})
+	}
+	return dataset, nil
+}
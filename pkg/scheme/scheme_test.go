@@ -0,0 +1,142 @@
+package scheme
+
+import (
+	"ConjunctiveSSE/pkg/HDXT"
+	"ConjunctiveSSE/pkg/ODXT"
+	"ConjunctiveSSE/pkg/utils"
+	"errors"
+	"slices"
+	"testing"
+)
+
+var testDataset = Dataset{
+	{"id1", []string{"a", "b"}},
+	{"id2", []string{"a", "c"}},
+	{"id3", []string{"a", "b", "c"}},
+	{"id4", []string{"b", "c"}},
+}
+
+func testSchemes(t *testing.T) []Scheme {
+	odxt, err := NewODXT(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dense, err := NewHDXT(HDXT.Dense, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparse, err := NewHDXT(HDXT.Sparse, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oxt, err := NewOXT(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	naive, err := NewMitraNaive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bdxt, err := NewBDXT(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonFP, err := NewODXTNonFP(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []Scheme{odxt, dense, sparse, oxt, naive, bdxt, nonFP}
+}
+
+func search(t *testing.T, s Scheme, q ...string) []string {
+	ids, _, err := s.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSchemes(t *testing.T) {
+	for _, s := range testSchemes(t) {
+		if err := s.Setup(testDataset); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := search(t, s, "a", "b"), []string{"id1", "id3"}; !slices.Equal(got, want) {
+			t.Errorf("%s: Search(a, b) = %v, want %v", s.Name(), got, want)
+		}
+		if got, want := search(t, s, "a", "b", "c"), []string{"id3"}; !slices.Equal(got, want) {
+			t.Errorf("%s: Search(a, b, c) = %v, want %v", s.Name(), got, want)
+		}
+
+		err := s.Update(utils.Add, "id5", []string{"a", "b", "d"})
+		if errors.Is(err, ErrStatic) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Update(utils.Del, "id1", []string{"a", "b"}); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := search(t, s, "a", "b"), []string{"id3", "id5"}; !slices.Equal(got, want) {
+			t.Errorf("%s: Search(a, b) after update = %v, want %v", s.Name(), got, want)
+		}
+		if got, want := search(t, s, "d", "b"), []string{"id5"}; !slices.Equal(got, want) {
+			t.Errorf("%s: Search(d, b) = %v, want %v", s.Name(), got, want)
+		}
+	}
+}
+
+func TestODXTNonFPEnumerate(t *testing.T) {
+	s, err := NewODXTNonFP(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Setup(testDataset); err != nil {
+		t.Fatal(err)
+	}
+	// 服务器多返回的条目没有对应的 xtoken
+	s.UpdateCnt["a"]--
+	if _, _, err := s.Search([]string{"a", "b"}); err == nil {
+		t.Error("Search with more enumerated entries than xtokens succeeded")
+	}
+	// 服务器少返回的条目同样被拒绝
+	s.UpdateCnt["a"] += 2
+	if _, _, err := s.Search([]string{"a"}); err == nil {
+		t.Error("Search with fewer enumerated entries than xtokens succeeded")
+	}
+	s.UpdateCnt["a"]--
+
+	// 存储错误不被当作列表结束
+	s.Store = failingStore{s.Store}
+	if _, _, err := s.Search([]string{"a", "b"}); !errors.Is(err, errStore) {
+		t.Errorf("Search with a failing store error = %v, want %v", err, errStore)
+	}
+}
+
+var errStore = errors.New("store unavailable")
+
+// failingStore 查询总是失败的存储
+type failingStore struct {
+	ODXT.Store
+}
+
+func (failingStore) Search([]string) ([]ODXT.SearchPayload, error) {
+	return nil, errStore
+}
+
+func TestOXTTSetRetrieve(t *testing.T) {
+	s, err := NewOXT(ODXT.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Setup(testDataset); err != nil {
+		t.Fatal(err)
+	}
+	// 服务器取回 TSet 直到第一个不存在的元组，不依赖客户端的计数
+	s.Counts["a"]--
+	if _, _, err := s.Search([]string{"a", "b"}); err == nil {
+		t.Error("Search with fewer counted tuples than stored succeeded")
+	}
+}
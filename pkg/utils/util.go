@@ -20,12 +20,15 @@ type Operation int
 const (
 	Del Operation = iota // 0
 	Add                  // 1
+	Dummy                // 2 填充条目，解密时丢弃
 )
 
 type SEOp struct {
 	J    int
 	Sval string
 	Cnt  int
+	// Hits 为该行每个 xtoken 的 xtag 是否在 XSet 中，顺序与服务器收到的 xtoken 相同
+	Hits []bool
 }
 
 
@@ -47,8 +50,8 @@ func BytesXORWithOp(mac, id []byte, op int) ([]byte, error) {
 	}
 
 	// 将MAC的最后一个字节与op异或
-	if op != 0 && op != 1 {
-		return nil, fmt.Errorf("op must be 0 or 1")
+	if op < int(Del) || op > int(Dummy) {
+		return nil, fmt.Errorf("op must be 0, 1 or 2")
 	}
 	mac[31] = mac[31] ^ byte(op)
 
@@ -253,6 +256,36 @@ func SaveUpdateCntToFile(updateCnt map[string]int, filename string) error {
 	return encoder.Encode(updateCnt)
 }
 
+// SaveJSONToFile 将 v 以 JSON 格式保存到文件
+func SaveJSONToFile(v any, filename string) error {
+	// 创建文件，如果所在目录不存在，则先创建目录，再创建文件
+	dir := filepath.Dir(filename)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		os.MkdirAll(dir, 0755)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// LoadJSONFromFile 从 JSON 文件加载数据到 v
+func LoadJSONFromFile(v any, filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(v)
+}
+
 // LoadUpdateCntFromFile 从文件加载 UpdateCnt
 func LoadUpdateCntFromFile(filename string) (map[string]int, error) {
 	file, err := os.Open(filename)